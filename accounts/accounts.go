@@ -0,0 +1,168 @@
+package accounts
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minNameLength     = 3
+	maxNameLength     = 20
+	minPasswordLength = 8
+	// bcrypt ignores everything after 72 bytes
+	maxPasswordLength = 72
+	defaultSessionTtl = 30 * 24 * time.Hour
+)
+
+var (
+	InvalidName        = errors.New("user name must be 3-20 letters, digits, '-' or '_'")
+	InvalidPassword    = errors.New("password must be 8-72 bytes long")
+	NameTaken          = errors.New("user name already taken")
+	InvalidCredentials = errors.New("invalid user name or password")
+	InvalidSession     = errors.New("invalid or expired session")
+)
+
+type User struct {
+	Id           int
+	Name         string
+	Created      time.Time
+	passwordHash []byte
+}
+
+type session struct {
+	userId  int
+	expires time.Time
+}
+
+var (
+	mu         sync.RWMutex
+	users      = make(map[int]*User)
+	userNames  = make(map[string]*User)
+	sessions   = make(map[string]session)
+	nextUserId = 1
+	// blocks holds the users each user has blocked
+	blocks = make(map[int]map[int]bool)
+	// dummyHash is compared against when the user does not exist, so that
+	// failing logins take the same time either way
+	dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+)
+
+// Register creates a user with a bcrypt hash of the password.
+func Register(name, password string) (*User, error) {
+	if !validName(name) {
+		return nil, InvalidName
+	}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, InvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if userNames[name] != nil {
+		return nil, NameTaken
+	}
+	u := &User{Id: nextUserId, Name: name, Created: time.Now(), passwordHash: hash}
+	nextUserId++
+	users[u.Id] = u
+	userNames[name] = u
+	return u, nil
+}
+
+// Login checks the password and starts a session, the returned token
+// identifies it until it expires.
+func Login(name, password string) (*User, string, time.Time, error) {
+	mu.RLock()
+	u := userNames[name]
+	mu.RUnlock()
+	hash := dummyHash
+	if u != nil {
+		hash = u.passwordHash
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || u == nil {
+		return nil, "", time.Time{}, InvalidCredentials
+	}
+	token := rand.Text()
+	expires := time.Now().Add(sessionTtl())
+	mu.Lock()
+	sessions[token] = session{userId: u.Id, expires: expires}
+	mu.Unlock()
+	return u, token, expires, nil
+}
+
+// Logout ends the session.
+func Logout(token string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(sessions, token)
+}
+
+// UserBySession returns the user of a running session.
+func UserBySession(token string) (*User, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := sessions[token]
+	if !ok {
+		return nil, InvalidSession
+	}
+	if time.Now().After(s.expires) {
+		delete(sessions, token)
+		return nil, InvalidSession
+	}
+	return users[s.userId], nil
+}
+
+func GetUserById(id int) *User {
+	mu.RLock()
+	defer mu.RUnlock()
+	return users[id]
+}
+
+// Block hides the chat messages of blockedId from userId.
+func Block(userId, blockedId int) {
+	mu.Lock()
+	defer mu.Unlock()
+	if blocks[userId] == nil {
+		blocks[userId] = make(map[int]bool)
+	}
+	blocks[userId][blockedId] = true
+}
+
+func Unblock(userId, blockedId int) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(blocks[userId], blockedId)
+}
+
+func IsBlocked(userId, otherId int) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return blocks[userId][otherId]
+}
+
+func sessionTtl() time.Duration {
+	if ttl := viper.GetDuration("accounts.sessionTtl"); ttl > 0 {
+		return ttl
+	}
+	return defaultSessionTtl
+}
+
+func validName(name string) bool {
+	if len(name) < minNameLength || len(name) > maxNameLength {
+		return false
+	}
+	for _, r := range name {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package accounts
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	u, err := Register("magnus", "correct horse")
+	if err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	tests := []struct {
+		name     string
+		password string
+		eError   error
+	}{
+		{name: "magnus", password: "another one", eError: NameTaken},
+		{name: "m", password: "correct horse", eError: InvalidName},
+		{name: "bad name", password: "correct horse", eError: InvalidName},
+		{name: "hikaru", password: "short", eError: InvalidPassword},
+	}
+	for _, test := range tests {
+		if _, err = Register(test.name, test.password); !errors.Is(err, test.eError) {
+			t.Errorf("Register(%v) expected error: %v, got: %v", test.name, test.eError, err)
+		}
+	}
+
+	if _, _, _, err = Login("magnus", "wrong password"); !errors.Is(err, InvalidCredentials) {
+		t.Errorf("Login() with a wrong password expected error: %v, got: %v", InvalidCredentials, err)
+	}
+	if _, _, _, err = Login("nobody", "correct horse"); !errors.Is(err, InvalidCredentials) {
+		t.Errorf("Login() of an unknown user expected error: %v, got: %v", InvalidCredentials, err)
+	}
+	_, token, _, err := Login("magnus", "correct horse")
+	if err != nil {
+		t.Fatalf("Login() error: %v", err)
+	}
+	if session, err := UserBySession(token); err != nil || session != u {
+		t.Errorf("UserBySession() expected %v, got: %v, %v", u.Name, session, err)
+	}
+	Logout(token)
+	if _, err = UserBySession(token); !errors.Is(err, InvalidSession) {
+		t.Errorf("UserBySession() after logout expected error: %v, got: %v", InvalidSession, err)
+	}
+}
@@ -0,0 +1,39 @@
+package book
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	defaultBook *Book
+	loadOnce    sync.Once
+)
+
+// Default returns the book configured by book.path, or nil when none is
+// configured or it cannot be read. A nil book has no moves.
+func Default() *Book {
+	loadOnce.Do(func() {
+		path := viper.GetString("book.path")
+		if path == "" {
+			return
+		}
+		b, err := Open(path)
+		if err != nil {
+			slog.Error("Error opening opening book", "path", path, "error", err)
+			return
+		}
+		defaultBook = b
+	})
+	return defaultBook
+}
+
+// ConfiguredSelection returns the move selection set by book.selection.
+func ConfiguredSelection() Selection {
+	if Selection(viper.GetString("book.selection")) == Best {
+		return Best
+	}
+	return Weighted
+}
@@ -0,0 +1,80 @@
+package book
+
+import "lets-go-chess/game"
+
+const (
+	castlingOffset  = 768
+	enPassantOffset = 772
+	turnOffset      = 780
+)
+
+// Hash returns the Polyglot key of the current position.
+func Hash(g *game.Game) uint64 {
+	var key uint64
+	for pos, figure := range g.Field.Cells {
+		if figure == nil {
+			continue
+		}
+		key ^= random64[64*pieceKind(figure)+8*(pos.Y-1)+pos.X-1]
+	}
+	// castling keys are in the order white short, white long, black short,
+	// black long
+	rights := g.CastlingRights()
+	for i, available := range []bool{rights.WhiteKingside, rights.WhiteQueenside, rights.BlackKingside, rights.BlackQueenside} {
+		if available {
+			key ^= random64[castlingOffset+i]
+		}
+	}
+	if file, ok := enPassantFile(g); ok {
+		key ^= random64[enPassantOffset+file-1]
+	}
+	if g.IsWhiteMove {
+		key ^= random64[turnOffset]
+	}
+	return key
+}
+
+// pieceKind numbers pieces the Polyglot way: black pawn 0, white pawn 1,
+// black knight 2 and so on up to white king 11.
+func pieceKind(figure *game.Figure) int {
+	var kind int
+	switch figure.Mover.(type) {
+	case game.Pawn:
+		kind = 0
+	case game.Knight:
+		kind = 2
+	case game.Bishop:
+		kind = 4
+	case game.Rook:
+		kind = 6
+	case game.Queen:
+		kind = 8
+	case game.King:
+		kind = 10
+	}
+	if figure.IsWhite {
+		kind++
+	}
+	return kind
+}
+
+// enPassantFile returns the file of a pawn which has just made a double step,
+// but only if a pawn of the side to move stands ready to capture it, as the
+// Polyglot format requires.
+func enPassantFile(g *game.Game) (int, bool) {
+	for pos, figure := range g.Field.Cells {
+		if figure == nil || !figure.IsVulnerableForEnPassant || figure.IsWhite == g.IsWhiteMove {
+			continue
+		}
+		for _, dx := range []int{-1, 1} {
+			neighbour := g.Field.Cells[game.Position{X: pos.X + dx, Y: pos.Y}]
+			if neighbour == nil || neighbour.IsWhite != g.IsWhiteMove {
+				continue
+			}
+			if _, isPawn := neighbour.Mover.(game.Pawn); isPawn {
+				return pos.X, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,160 @@
+package book
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"lets-go-chess/game"
+	"math/rand/v2"
+	"os"
+	"sort"
+)
+
+const entrySize = 16
+
+var InvalidBook = errors.New("invalid polyglot book")
+
+type Selection string
+
+const (
+	Weighted Selection = "weighted"
+	Best     Selection = "best"
+)
+
+type entry struct {
+	key    uint64
+	move   uint16
+	weight uint16
+}
+
+// Book is a Polyglot opening book loaded into memory.
+type Book struct {
+	entries []entry
+}
+
+type BookMove struct {
+	Move   game.Move
+	Weight int
+}
+
+// Open reads a Polyglot .bin book.
+func Open(path string) (*Book, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes Polyglot book data: 16 byte big endian entries sorted by key.
+func Parse(data []byte) (*Book, error) {
+	if len(data)%entrySize != 0 {
+		return nil, fmt.Errorf("%w: size %d is not a multiple of %d", InvalidBook, len(data), entrySize)
+	}
+	b := &Book{entries: make([]entry, 0, len(data)/entrySize)}
+	for offset := 0; offset < len(data); offset += entrySize {
+		b.entries = append(b.entries, entry{
+			key:    binary.BigEndian.Uint64(data[offset:]),
+			move:   binary.BigEndian.Uint16(data[offset+8:]),
+			weight: binary.BigEndian.Uint16(data[offset+10:]),
+		})
+	}
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		return b.entries[i].key < b.entries[j].key
+	})
+	return b, nil
+}
+
+// Moves returns the legal book moves for the current position, the most
+// weighted first.
+func (b *Book) Moves(g *game.Game) []BookMove {
+	var moves []BookMove
+	if b == nil {
+		return moves
+	}
+	key := Hash(g)
+	legal := g.LegalMoves()
+	i := sort.Search(len(b.entries), func(i int) bool {
+		return b.entries[i].key >= key
+	})
+	for ; i < len(b.entries) && b.entries[i].key == key; i++ {
+		m := decodeMove(g, b.entries[i].move)
+		if !contains(legal, m) {
+			continue
+		}
+		moves = append(moves, BookMove{Move: m, Weight: int(b.entries[i].weight)})
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].Weight > moves[j].Weight
+	})
+	return moves
+}
+
+// Pick chooses a book move, either the most weighted one or a random one
+// with probability proportional to its weight.
+func (b *Book) Pick(g *game.Game, selection Selection) (game.Move, bool) {
+	moves := b.Moves(g)
+	if len(moves) == 0 {
+		return game.Move{}, false
+	}
+	if selection == Best {
+		return moves[0].Move, true
+	}
+	total := 0
+	for _, m := range moves {
+		total += m.Weight
+	}
+	if total == 0 {
+		return moves[rand.IntN(len(moves))].Move, true
+	}
+	n := rand.IntN(total)
+	for _, m := range moves {
+		if n < m.Weight {
+			return m.Move, true
+		}
+		n -= m.Weight
+	}
+	return moves[0].Move, true
+}
+
+// decodeMove unpacks a Polyglot move: to file, to row, from file, from row
+// and promotion piece, three bits each. Castling is stored as the king
+// capturing its own rook.
+func decodeMove(g *game.Game, raw uint16) game.Move {
+	m := game.Move{
+		To:   game.Position{X: int(raw&7) + 1, Y: int(raw>>3&7) + 1},
+		From: game.Position{X: int(raw>>6&7) + 1, Y: int(raw>>9&7) + 1},
+	}
+	switch raw >> 12 & 7 {
+	case 1:
+		m.Promotion = game.Knight{}
+	case 2:
+		m.Promotion = game.Bishop{}
+	case 3:
+		m.Promotion = game.Rook{}
+	case 4:
+		m.Promotion = game.Queen{}
+	}
+	figure := g.Field.Cells[m.From]
+	if figure == nil {
+		return m
+	}
+	if _, isKing := figure.Mover.(game.King); isKing && m.From.X == 5 && m.From.Y == m.To.Y {
+		switch m.To.X {
+		case 8:
+			m.To.X = 7
+		case 1:
+			m.To.X = 3
+		}
+	}
+	return m
+}
+
+func contains(moves []game.Move, move game.Move) bool {
+	for _, m := range moves {
+		if m == move {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package book
+
+import (
+	"encoding/binary"
+	"lets-go-chess/game"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		moves []string
+		eKey  uint64
+	}{
+		{moves: nil, eKey: 0x463b96181691fc9c},
+		{moves: []string{"e2e4"}, eKey: 0x823c9b50fd114196},
+		{moves: []string{"e2e4", "d7d5"}, eKey: 0x0756b94461c50fb0},
+		{moves: []string{"e2e4", "d7d5", "e4e5"}, eKey: 0x662fafb965db29d4},
+		{moves: []string{"e2e4", "d7d5", "e4e5", "f7f5"}, eKey: 0x22a48b5a8e47ff78},
+		{moves: []string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2"}, eKey: 0x652a607ca3f242c1},
+		{moves: []string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2", "e8f7"}, eKey: 0x00fdd303c946bdd9},
+		{moves: []string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4"}, eKey: 0x3c8123ea7b067637},
+		{moves: []string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4", "b4c3", "a1a3"}, eKey: 0x5c3f9b829b279560},
+	}
+	for _, test := range tests {
+		g := playMoves(t, test.moves)
+		if key := Hash(g); key != test.eKey {
+			t.Errorf("Hash() after %v expected: %016x, got: %016x", test.moves, test.eKey, key)
+		}
+	}
+}
+
+func TestBookMoves(t *testing.T) {
+	start := game.StartGame()
+	afterE4 := playMoves(t, []string{"e2e4"})
+	data := append(encodeEntry(Hash(start), "e2e4", 10), encodeEntry(Hash(start), "d2d4", 30)...)
+	data = append(data, encodeEntry(Hash(afterE4), "e7e5", 5)...)
+	data = append(data, encodeEntry(Hash(start), "e2e5", 50)...)
+	b, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	moves := b.Moves(start)
+	if len(moves) != 2 || moves[0].Move.String() != "d2d4" || moves[1].Move.String() != "e2e4" {
+		t.Errorf("Moves() expected [d2d4 e2e4], got: %v", moves)
+	}
+	if m, ok := b.Pick(start, Best); !ok || m.String() != "d2d4" {
+		t.Errorf("Pick(Best) expected d2d4, got: %v, %v", m, ok)
+	}
+	if m, ok := b.Pick(afterE4, Weighted); !ok || m.String() != "e7e5" {
+		t.Errorf("Pick(Weighted) expected e7e5, got: %v, %v", m, ok)
+	}
+	if _, ok := b.Pick(playMoves(t, []string{"a2a3"}), Weighted); ok {
+		t.Errorf("Pick() expected no move out of book")
+	}
+	if _, err = Parse(data[:20]); err == nil {
+		t.Errorf("Parse() expected error for truncated book")
+	}
+}
+
+func TestDecodeCastling(t *testing.T) {
+	g := playMoves(t, []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "g8f6"})
+	data := encodeEntry(Hash(g), "e1h1", 1)
+	b, _ := Parse(data)
+	moves := b.Moves(g)
+	if len(moves) != 1 || moves[0].Move.String() != "e1g1" {
+		t.Errorf("Moves() expected [e1g1], got: %v", moves)
+	}
+}
+
+func playMoves(t *testing.T, moves []string) *game.Game {
+	g := game.StartGame()
+	for _, notation := range moves {
+		m, err := game.ParseMove(notation)
+		if err != nil {
+			t.Fatalf("ParseMove(%v) error: %v", notation, err)
+		}
+		if _, err = g.Play(m); err != nil {
+			t.Fatalf("Play(%v) error: %v", notation, err)
+		}
+	}
+	return g
+}
+
+func encodeEntry(key uint64, notation string, weight uint16) []byte {
+	m, _ := game.ParseMove(notation)
+	raw := uint16(m.To.X-1) | uint16(m.To.Y-1)<<3 | uint16(m.From.X-1)<<6 | uint16(m.From.Y-1)<<9
+	data := make([]byte, entrySize)
+	binary.BigEndian.PutUint64(data, key)
+	binary.BigEndian.PutUint16(data[8:], raw)
+	binary.BigEndian.PutUint16(data[10:], weight)
+	return data
+}
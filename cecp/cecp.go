@@ -0,0 +1,268 @@
+package cecp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"lets-go-chess/engine"
+	"lets-go-chess/game"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// maxDepth caps the search when only the clock limits it.
+const maxDepth = 64
+
+const features = `feature ping=1 setboard=0 playother=0 usermove=1 time=1 draw=0 sigint=0 sigterm=0 reuse=1 analyze=0 colors=0 myname="lets-go-chess" done=1`
+
+// session keeps the state of one XBoard connection.
+type session struct {
+	out         io.Writer
+	g           *game.Game
+	force       bool
+	engineWhite bool
+	depth       int
+	moveTime    time.Duration
+	movesPerTC  int
+	base        time.Duration
+	increment   time.Duration
+	clock       time.Duration
+}
+
+// StartEngine speaks the Chess Engine Communication Protocol on stdin and stdout.
+func StartEngine() {
+	err := Run(os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading commands", err)
+	}
+}
+
+// Run serves CECP commands from in until "quit" or the end of input.
+func Run(in io.Reader, out io.Writer) error {
+	s := &session{out: out}
+	s.newGame()
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" {
+			return nil
+		}
+		s.handle(fields[0], fields[1:])
+	}
+	return scanner.Err()
+}
+
+func (s *session) handle(command string, args []string) {
+	switch command {
+	case "xboard", "accepted", "rejected", "random", "post", "nopost", "hard", "easy", "computer", "otim":
+	case "protover":
+		s.send(features)
+	case "ping":
+		s.send("pong " + strings.Join(args, " "))
+	case "new":
+		s.newGame()
+	case "force":
+		s.force = true
+	case "go":
+		s.force = false
+		s.engineWhite = s.g.IsWhiteMove
+		s.think()
+	case "usermove":
+		if len(args) == 1 {
+			s.userMove(args[0])
+		}
+	case "level":
+		s.level(args)
+	case "st":
+		if len(args) == 1 {
+			seconds, err := strconv.Atoi(args[0])
+			if err == nil {
+				s.moveTime = time.Duration(seconds) * time.Second
+			}
+		}
+	case "sd":
+		if len(args) == 1 {
+			depth, err := strconv.Atoi(args[0])
+			if err == nil {
+				s.depth = depth
+			}
+		}
+	case "time":
+		if len(args) == 1 {
+			centiseconds, err := strconv.Atoi(args[0])
+			if err == nil {
+				s.clock = time.Duration(centiseconds) * 10 * time.Millisecond
+			}
+		}
+	case "undo":
+		s.undo(1)
+	case "remove":
+		s.undo(2)
+	case "result":
+		s.result(args)
+	default:
+		if _, err := game.ParseMove(command); err == nil {
+			s.userMove(command)
+			return
+		}
+		s.send("Error (unknown command): " + command)
+	}
+}
+
+func (s *session) newGame() {
+	s.g = game.StartGame()
+	s.force = false
+	s.engineWhite = false
+	s.depth = 0
+	s.clock = s.base
+}
+
+func (s *session) userMove(notation string) {
+	m, err := game.ParseMove(notation)
+	if err != nil {
+		s.send("Illegal move: " + notation)
+		return
+	}
+	if s.g.Result != game.Ongoing {
+		s.send("Illegal move (game over): " + notation)
+		return
+	}
+	if _, err = s.g.Play(m); err != nil {
+		s.send("Illegal move: " + notation)
+		return
+	}
+	if s.reportResult() {
+		return
+	}
+	if !s.force && s.g.IsWhiteMove == s.engineWhite {
+		s.think()
+	}
+}
+
+func (s *session) think() {
+	if s.g.Result != game.Ongoing {
+		return
+	}
+	m, err := engine.Think(s.g, s.limits())
+	if err != nil {
+		return
+	}
+	if _, err := s.g.Play(m); err != nil {
+		return
+	}
+	s.send("move " + m.String())
+	s.reportResult()
+}
+
+// limits turns the time control set by level, st and sd into search limits.
+func (s *session) limits() engine.Limits {
+	limits := engine.Limits{Depth: s.depth, Threads: viper.GetInt("engine.threads")}
+	if s.moveTime > 0 {
+		limits.MoveTime = s.moveTime
+		return limits
+	}
+	if s.clock <= 0 {
+		return limits
+	}
+	movesLeft := 30
+	if s.movesPerTC > 0 {
+		played := len(s.g.Moves) / 2
+		movesLeft = s.movesPerTC - played%s.movesPerTC
+	}
+	limits.MoveTime = s.clock/time.Duration(movesLeft) + s.increment/2
+	if limits.Depth == 0 {
+		limits.Depth = maxDepth
+	}
+	return limits
+}
+
+// level parses "level MPS BASE INC", where BASE is minutes or minutes:seconds.
+func (s *session) level(args []string) {
+	if len(args) != 3 {
+		return
+	}
+	mps, err := strconv.Atoi(args[0])
+	if err != nil {
+		return
+	}
+	base, err := parseBase(args[1])
+	if err != nil {
+		return
+	}
+	inc, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		return
+	}
+	s.movesPerTC = mps
+	s.base = base
+	s.increment = time.Duration(inc * float64(time.Second))
+	s.clock = base
+	s.moveTime = 0
+}
+
+func parseBase(base string) (time.Duration, error) {
+	minutes, seconds, hasSeconds := strings.Cut(base, ":")
+	m, err := strconv.Atoi(minutes)
+	if err != nil {
+		return 0, err
+	}
+	d := time.Duration(m) * time.Minute
+	if hasSeconds {
+		sec, err := strconv.Atoi(seconds)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(sec) * time.Second
+	}
+	return d, nil
+}
+
+func (s *session) undo(plies int) {
+	for i := 0; i < plies; i++ {
+		if err := s.g.Undo(); err != nil {
+			return
+		}
+	}
+}
+
+// result records the outcome announced by the GUI, e.g. "result 1-0 {White mates}".
+func (s *session) result(args []string) {
+	if len(args) == 0 {
+		return
+	}
+	switch args[0] {
+	case "1-0":
+		s.g.Finish(game.WhiteWon)
+	case "0-1":
+		s.g.Finish(game.BlackWon)
+	case "1/2-1/2":
+		s.g.Finish(game.Draw)
+	}
+	s.force = true
+}
+
+// reportResult tells the GUI when the last move finished the game.
+func (s *session) reportResult() bool {
+	switch s.g.Result {
+	case game.WhiteWon:
+		s.send("1-0 {White mates}")
+	case game.BlackWon:
+		s.send("0-1 {Black mates}")
+	case game.Draw:
+		s.send("1/2-1/2 {Stalemate}")
+	default:
+		return false
+	}
+	return true
+}
+
+func (s *session) send(line string) {
+	fmt.Fprintln(s.out, line)
+}
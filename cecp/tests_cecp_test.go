@@ -0,0 +1,90 @@
+package cecp
+
+import (
+	"bytes"
+	"lets-go-chess/game"
+	"strings"
+	"testing"
+)
+
+// run feeds the commands to a session and returns the lines it answered.
+func run(t *testing.T, commands ...string) []string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := Run(strings.NewReader(strings.Join(commands, "\n")+"\n"), &out); err != nil {
+		t.Fatalf("Run expected to succeed, got: %v", err)
+	}
+	output := strings.TrimSpace(out.String())
+	if output == "" {
+		return nil
+	}
+	return strings.Split(output, "\n")
+}
+
+func TestProtover(t *testing.T) {
+	lines := run(t, "xboard", "protover 2", "ping 7", "frobnicate", "quit", "ping 8")
+	if len(lines) != 3 {
+		t.Fatalf("expected features, pong and an error, got: %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "feature ") || !strings.Contains(lines[0], "usermove=1") || !strings.HasSuffix(lines[0], "done=1") {
+		t.Errorf("protover expected the features ending with done=1, got: %v", lines[0])
+	}
+	if lines[1] != "pong 7" {
+		t.Errorf("ping expected pong 7, got: %v", lines[1])
+	}
+	if lines[2] != "Error (unknown command): frobnicate" {
+		t.Errorf("unknown command expected an error, got: %v", lines[2])
+	}
+}
+
+func TestUserMoveAndReply(t *testing.T) {
+	lines := run(t, "new", "sd 1", "usermove e2e4")
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "move ") {
+		t.Fatalf("usermove expected the engine to reply, got: %q", lines)
+	}
+	g := game.StartGame()
+	for _, notation := range []string{"e2e4", strings.TrimPrefix(lines[0], "move ")} {
+		m, err := game.ParseMove(notation)
+		if err == nil {
+			_, err = g.Play(m)
+		}
+		if err != nil {
+			t.Errorf("expected %v to be legal, got: %v", notation, err)
+		}
+	}
+
+	// a bare move works without the usermove feature, go lets the engine
+	// play the side to move
+	lines = run(t, "new", "sd 1", "force", "e2e4", "go")
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "move ") {
+		t.Errorf("go expected the engine to move for black, got: %q", lines)
+	}
+	if lines = run(t, "new", "usermove e2e5"); len(lines) != 1 || lines[0] != "Illegal move: e2e5" {
+		t.Errorf("expected the illegal move to be refused, got: %q", lines)
+	}
+}
+
+func TestUndoAndRemove(t *testing.T) {
+	lines := run(t, "new", "force", "usermove e2e4", "usermove e7e5", "remove", "usermove e7e5", "usermove e2e4")
+	if len(lines) != 1 || lines[0] != "Illegal move: e7e5" {
+		t.Errorf("remove expected to take back both moves, got: %q", lines)
+	}
+	lines = run(t, "new", "force", "usermove e2e4", "undo", "usermove d2d4", "usermove e7e5")
+	if len(lines) != 0 {
+		t.Errorf("undo expected to take back the last move, got: %q", lines)
+	}
+}
+
+func TestResult(t *testing.T) {
+	lines := run(t, "new", "force", "usermove f2f3", "usermove e7e5", "usermove g2g4", "usermove d8h4")
+	if len(lines) != 1 || lines[0] != "0-1 {Black mates}" {
+		t.Errorf("mate expected to be reported, got: %q", lines)
+	}
+	lines = run(t, "new", "sd 1", "usermove e2e4", "result 1-0 {White wins on time}", "usermove e7e5", "go")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "move ") {
+		t.Fatalf("expected the engine reply before the result, got: %q", lines)
+	}
+	if lines[1] != "Illegal move (game over): e7e5" {
+		t.Errorf("moves after the result expected to be refused, got: %v", lines[1])
+	}
+}
@@ -2,32 +2,42 @@ package cli
 
 import (
 	"fmt"
+	"lets-go-chess/engine"
 	"lets-go-chess/game"
+
+	"github.com/spf13/viper"
 )
 
 func StartGame() {
 	g := game.StartGame()
+	fmt.Printf("Enter computer level %d-%d, or 0 to play against a human: ", engine.MinSkill, engine.MaxSkill)
+	var level int
+	fmt.Scan(&level)
+	if level < 0 || level > engine.MaxSkill {
+		level = 0
+	}
 	var move string
 	for {
 		game.DrawConsoleBoard(g.Field)
-		fmt.Print("Enter your move: ")
+		fmt.Print("Enter your move, e.g. e2e4 or Nf3: ")
 		fmt.Scan(&move)
 
 		if move == "exit" {
 			break
 		}
 
-		runes := []rune(move)
-		fromY := runes[0] - 96
-		fromX := runes[1] - 48
-		toY := runes[2] - 96
-		toX := runes[3] - 48
-
-		situation, moveErr := g.NextMove(game.Position{X: int(fromX), Y: int(fromY)}, game.Position{X: int(toX), Y: int(toY)})
+		m, moveErr := g.ParseNotation(move)
+		var situation game.Situation
+		if moveErr == nil {
+			situation, moveErr = g.Play(m)
+		}
 		if moveErr != nil {
 			fmt.Print("\033[31m", moveErr, "\033[0m\n")
 			continue
 		}
+		if level != 0 && situation != game.Checkmate && situation != game.Stalemate {
+			situation = computerMove(g, level)
+		}
 		switch situation {
 		case game.Continue:
 			continue
@@ -43,3 +53,21 @@ func StartGame() {
 		}
 	}
 }
+
+// computerMove lets the computer reply as black and prints its move.
+func computerMove(g *game.Game, level int) game.Situation {
+	m, err := engine.Think(g, engine.Limits{
+		MoveTime: viper.GetDuration("engine.moveTime"),
+		Threads:  viper.GetInt("engine.threads"),
+		Skill:    level,
+	})
+	if err != nil {
+		return game.Stalemate
+	}
+	situation, err := g.Play(m)
+	if err != nil {
+		return game.Stalemate
+	}
+	fmt.Printf("Computer plays %v (%v)\n", g.LastMoveSAN(), m)
+	return situation
+}
@@ -0,0 +1,58 @@
+package engine
+
+import "lets-go-chess/game"
+
+// Evaluate scores the position in centipawns from the point of view of the
+// side to move.
+func Evaluate(g *game.Game) int {
+	score := 0
+	for pos, figure := range g.Field.Cells {
+		if figure == nil {
+			continue
+		}
+		value := pieceValue(figure.Mover) + centreBonus(figure.Mover, pos)
+		if figure.IsWhite {
+			score += value
+		} else {
+			score -= value
+		}
+	}
+	if !g.IsWhiteMove {
+		score = -score
+	}
+	return score
+}
+
+func pieceValue(mover game.Mover) int {
+	switch mover.(type) {
+	case game.Pawn:
+		return 100
+	case game.Knight:
+		return 320
+	case game.Bishop:
+		return 330
+	case game.Rook:
+		return 500
+	case game.Queen:
+		return 900
+	}
+	return 0
+}
+
+// centreBonus rewards minor pieces and pawns for controlling the centre.
+func centreBonus(mover game.Mover, pos game.Position) int {
+	switch mover.(type) {
+	case game.Pawn, game.Knight, game.Bishop:
+	default:
+		return 0
+	}
+	distX := pos.X*2 - 9
+	if distX < 0 {
+		distX = -distX
+	}
+	distY := pos.Y*2 - 9
+	if distY < 0 {
+		distY = -distY
+	}
+	return 14 - distX - distY
+}
@@ -0,0 +1,309 @@
+package engine
+
+import (
+	"errors"
+	"lets-go-chess/book"
+	"lets-go-chess/game"
+	"lets-go-chess/tablebase"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultDepth = 3
+	mateScore    = 100000
+	mateBound    = mateScore - 1000
+	infinity     = mateScore + 1
+)
+
+var NoLegalMoves = errors.New("no legal moves")
+
+// Limits bound the search. Threads sets the number of search workers, zero
+// means one per GOMAXPROCS. A single thread searches deterministically.
+// Nodes caps the positions searched after the first iteration. Skill between
+// MinSkill and MaxSkill-1 weakens the engine, zero or MaxSkill plays at full
+// strength. Seed makes the mistakes of a weakened engine reproducible, zero
+// picks a random seed.
+type Limits struct {
+	Depth    int
+	MoveTime time.Duration
+	Threads  int
+	Nodes    int64
+	Skill    int
+	Seed     uint64
+}
+
+// searcher is one Lazy SMP worker. Workers search the same position
+// independently and share what they learn through the transposition table.
+type searcher struct {
+	id       int
+	tt       *transpositionTable
+	deadline time.Time
+	stop     *atomic.Bool
+	stopped  bool
+	nodes    *atomic.Int64
+	maxNodes int64
+	// completed is the depth of the last finished iteration
+	completed int
+	// fullWindow makes root score every move exactly, rootMoves and
+	// rootScores keep the result of the last finished iteration
+	fullWindow bool
+	rootMoves  []game.Move
+	rootScores []int
+}
+
+// bestResult collects the deepest finished iteration of all workers.
+type bestResult struct {
+	mu    sync.Mutex
+	move  game.Move
+	depth int
+}
+
+func (b *bestResult) update(move game.Move, depth int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if depth > b.depth {
+		b.move = move
+		b.depth = depth
+	}
+}
+
+// Search looks for the best move of the side to move using iterative
+// deepening on Limits.Threads workers. The best move of the deepest finished
+// iteration is returned when the time limit is hit. Endgames covered by the
+// tablebases are played from the tables. A weakened engine searches on one
+// worker and may pick a slightly worse move, see Limits.Skill.
+func Search(g *game.Game, limits Limits) (game.Move, error) {
+	moves := g.LegalMoves()
+	if len(moves) == 0 {
+		return game.Move{}, NoLegalMoves
+	}
+	if m, _, ok := tablebase.Default().BestMove(g); ok {
+		stats.tablebaseMoves.Add(1)
+		return m, nil
+	}
+	started := time.Now()
+	depth := limits.Depth
+	if depth <= 0 {
+		depth = DefaultDepth
+	}
+	threads := limits.Threads
+	if threads <= 0 {
+		threads = runtime.GOMAXPROCS(0)
+	}
+	maxNodes := limits.Nodes
+	weakened := isWeakened(limits.Skill)
+	sk := skillSettings(limits.Skill)
+	if weakened {
+		depth = min(depth, sk.depth)
+		if maxNodes <= 0 || sk.nodes < maxNodes {
+			maxNodes = sk.nodes
+		}
+		threads = 1
+	}
+	tt := newTranspositionTable()
+	stop := &atomic.Bool{}
+	nodes := &atomic.Int64{}
+	best := &bestResult{move: moves[0]}
+	var deadline time.Time
+	if limits.MoveTime > 0 {
+		deadline = time.Now().Add(limits.MoveTime)
+	}
+
+	var wg sync.WaitGroup
+	for id := 1; id < threads; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			s := &searcher{id: id, tt: tt, deadline: deadline, stop: stop, nodes: nodes, maxNodes: maxNodes}
+			s.iterate(g, moves, depth, best)
+		}(id)
+	}
+	main := &searcher{id: 0, tt: tt, deadline: deadline, stop: stop, nodes: nodes, maxNodes: maxNodes, fullWindow: weakened}
+	main.iterate(g, moves, depth, best)
+	stop.Store(true)
+	wg.Wait()
+	recordSearch(nodes.Load(), max(best.depth, main.completed), time.Since(started))
+	if weakened && main.rootMoves != nil {
+		return sk.choose(main.rootMoves, main.rootScores, newRand(limits.Seed)), nil
+	}
+	return best.move, nil
+}
+
+// iterate runs iterative deepening. Helper workers start one ply deeper on
+// every other worker and try the root moves in a rotated order, so that they
+// fill the table with different parts of the tree.
+func (s *searcher) iterate(g *game.Game, moves []game.Move, depth int, best *bestResult) {
+	moves = rotate(moves, s.id)
+	for d := 1 + s.id%2; d <= depth; d++ {
+		move, ok := s.root(g, moves, d)
+		if !ok {
+			return
+		}
+		s.completed = d
+		best.update(move, d)
+		moves = bringToFront(moves, move)
+	}
+}
+
+func (s *searcher) root(g *game.Game, moves []game.Move, depth int) (game.Move, bool) {
+	best := moves[0]
+	alpha := -infinity
+	var scored []game.Move
+	var scores []int
+	for _, m := range moves {
+		child := g.Clone()
+		if _, err := child.Play(m); err != nil {
+			continue
+		}
+		window := -alpha
+		if s.fullWindow {
+			window = infinity
+		}
+		score := -s.negamax(child, depth-1, 1, -infinity, window)
+		if s.stopped {
+			return best, false
+		}
+		scored = append(scored, m)
+		scores = append(scores, score)
+		if score > alpha {
+			alpha = score
+			best = m
+		}
+	}
+	if s.fullWindow {
+		s.rootMoves, s.rootScores = scored, scores
+	}
+	s.tt.store(book.Hash(g), ttData{score: alpha, depth: depth, flag: exact, move: best})
+	return best, true
+}
+
+func (s *searcher) negamax(g *game.Game, depth, ply, alpha, beta int) int {
+	if s.timeIsUp() {
+		return 0
+	}
+	s.nodes.Add(1)
+	switch g.Result {
+	case game.WhiteWon, game.BlackWon:
+		// the side to move has been mated, prefer the shortest mate
+		return -mateScore + ply
+	case game.Draw:
+		return 0
+	}
+	if result, ok := tablebase.Default().Probe(g); ok {
+		switch result.Outcome {
+		case tablebase.Win:
+			return mateScore - ply - result.Plies
+		case tablebase.Loss:
+			return -mateScore + ply + result.Plies
+		}
+		return 0
+	}
+	if depth <= 0 {
+		return Evaluate(g)
+	}
+
+	key := book.Hash(g)
+	var ttMove game.Move
+	if entry, ok := s.tt.probe(key); ok {
+		ttMove = entry.move
+		if entry.depth >= depth {
+			score := scoreFromTT(entry.score, ply)
+			switch {
+			case entry.flag == exact,
+				entry.flag == lowerBound && score >= beta,
+				entry.flag == upperBound && score <= alpha:
+				return score
+			}
+		}
+	}
+
+	alphaOrig := alpha
+	best := -infinity
+	var bestMove game.Move
+	moves := g.LegalMoves()
+	if ttMove.From != (game.Position{}) {
+		moves = bringToFront(moves, ttMove)
+	}
+	for _, m := range moves {
+		child := g.Clone()
+		if _, err := child.Play(m); err != nil {
+			continue
+		}
+		score := -s.negamax(child, depth-1, ply+1, -beta, -alpha)
+		if s.stopped {
+			return 0
+		}
+		if score > best {
+			best = score
+			bestMove = m
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+
+	flag := exact
+	if best <= alphaOrig {
+		flag = upperBound
+	} else if best >= beta {
+		flag = lowerBound
+	}
+	s.tt.store(key, ttData{score: scoreToTT(best, ply), depth: depth, flag: flag, move: bestMove})
+	return best
+}
+
+// Mate scores are stored relative to the node rather than the root, so that
+// they stay correct when the position is reached at another ply.
+func scoreToTT(score, ply int) int {
+	switch {
+	case score > mateBound:
+		return score + ply
+	case score < -mateBound:
+		return score - ply
+	}
+	return score
+}
+
+func scoreFromTT(score, ply int) int {
+	switch {
+	case score > mateBound:
+		return score - ply
+	case score < -mateBound:
+		return score + ply
+	}
+	return score
+}
+
+func (s *searcher) timeIsUp() bool {
+	if s.stopped {
+		return true
+	}
+	if s.stop.Load() || !s.deadline.IsZero() && time.Now().After(s.deadline) ||
+		s.maxNodes > 0 && s.completed > 0 && s.nodes.Load() >= s.maxNodes {
+		s.stop.Store(true)
+		s.stopped = true
+	}
+	return s.stopped
+}
+
+func bringToFront(moves []game.Move, move game.Move) []game.Move {
+	ordered := make([]game.Move, 0, len(moves))
+	ordered = append(ordered, move)
+	for _, m := range moves {
+		if m != move {
+			ordered = append(ordered, m)
+		}
+	}
+	return ordered
+}
+
+func rotate(moves []game.Move, n int) []game.Move {
+	n %= len(moves)
+	return append(append([]game.Move{}, moves[n:]...), moves[:n]...)
+}
@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats are the totals of all moves the engine picked since the process
+// started. Depth adds up the depth of the deepest finished iteration of each
+// search, divided by Searches it is the average depth.
+type Stats struct {
+	Searches       int64
+	BookMoves      int64
+	TablebaseMoves int64
+	Nodes          int64
+	Depth          int64
+	SearchTime     time.Duration
+}
+
+var stats struct {
+	searches, bookMoves, tablebaseMoves, nodes, depth, searchTime atomic.Int64
+}
+
+// ReadStats returns the totals so far.
+func ReadStats() Stats {
+	return Stats{
+		Searches:       stats.searches.Load(),
+		BookMoves:      stats.bookMoves.Load(),
+		TablebaseMoves: stats.tablebaseMoves.Load(),
+		Nodes:          stats.nodes.Load(),
+		Depth:          stats.depth.Load(),
+		SearchTime:     time.Duration(stats.searchTime.Load()),
+	}
+}
+
+func recordSearch(nodes int64, depth int, elapsed time.Duration) {
+	stats.searches.Add(1)
+	stats.nodes.Add(nodes)
+	stats.depth.Add(int64(depth))
+	stats.searchTime.Add(int64(elapsed))
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"lets-go-chess/game"
+	"math/rand/v2"
+)
+
+const (
+	MinSkill = 1
+	MaxSkill = 20
+	minElo   = 800
+	maxElo   = 2400
+)
+
+// SkillFromElo maps a target rating onto a skill level, 800 and below being
+// level 1 and 2400 and above full strength.
+func SkillFromElo(elo int) int {
+	switch {
+	case elo <= minElo:
+		return MinSkill
+	case elo >= maxElo:
+		return MaxSkill
+	}
+	return MinSkill + (elo-minElo)*(MaxSkill-MinSkill)/(maxElo-minElo)
+}
+
+// skill describes how a level below full strength plays: it searches less
+// and with some probability settles for a move scoring up to margin
+// centipawns below the best one.
+type skill struct {
+	depth         int
+	nodes         int64
+	margin        int
+	mistakeChance float64
+}
+
+func skillSettings(level int) skill {
+	weakness := MaxSkill - level
+	return skill{
+		depth:         1 + level/4,
+		nodes:         int64(level*level) * 20,
+		margin:        weakness * 20,
+		mistakeChance: float64(weakness) / MaxSkill,
+	}
+}
+
+func isWeakened(level int) bool {
+	return level >= MinSkill && level < MaxSkill
+}
+
+// choose picks among the root moves scored by the last finished iteration.
+// Mistakes are evaluation aware: a move is only considered when it loses at
+// most margin centipawns, and never when it walks into a mate the best move
+// avoids.
+func (sk skill) choose(moves []game.Move, scores []int, rng *rand.Rand) game.Move {
+	best := 0
+	for i := range scores {
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+	if rng.Float64() >= sk.mistakeChance {
+		return moves[best]
+	}
+	var candidates []int
+	total := 0
+	for i, score := range scores {
+		loss := scores[best] - score
+		if loss > sk.margin || score < -mateBound && scores[best] >= -mateBound {
+			continue
+		}
+		candidates = append(candidates, i)
+		total += sk.margin - loss + 1
+	}
+	n := rng.IntN(total)
+	for _, i := range candidates {
+		weight := sk.margin - (scores[best] - scores[i]) + 1
+		if n < weight {
+			return moves[i]
+		}
+		n -= weight
+	}
+	return moves[best]
+}
+
+func newRand(seed uint64) *rand.Rand {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return rand.New(rand.NewPCG(seed, seed))
+}
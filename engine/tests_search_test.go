@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"lets-go-chess/game"
+	"testing"
+)
+
+func TestSearchFindsMate(t *testing.T) {
+	for _, threads := range []int{1, 4} {
+		g := playMoves(t, []string{"e2e4", "e7e5", "f1c4", "b8c6", "d1h5", "g8f6"})
+		m, err := Search(g, Limits{Depth: 2, Threads: threads})
+		if err != nil {
+			t.Fatalf("Search() with %d threads error: %v", threads, err)
+		}
+		if m.String() != "h5f7" {
+			t.Errorf("Search() with %d threads expected h5f7, got: %v", threads, m)
+		}
+	}
+}
+
+func TestSearchSingleThreadIsDeterministic(t *testing.T) {
+	g := playMoves(t, []string{"e2e4", "e7e5", "g1f3"})
+	first, err := Search(g, Limits{Depth: 3, Threads: 1})
+	if err != nil {
+		t.Fatalf("Search() error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		m, _ := Search(g, Limits{Depth: 3, Threads: 1})
+		if m != first {
+			t.Errorf("Search() expected the same move %v on every run, got: %v", first, m)
+		}
+	}
+}
+
+func TestSearchNoLegalMoves(t *testing.T) {
+	g := playMoves(t, []string{"f2f3", "e7e5", "g2g4", "d8h4"})
+	if _, err := Search(g, Limits{Threads: 1}); err != NoLegalMoves {
+		t.Errorf("Search() expected error: %v, got: %v", NoLegalMoves, err)
+	}
+}
+
+func TestTranspositionTableRoundTrip(t *testing.T) {
+	tt := newTranspositionTable()
+	m, _ := game.ParseMove("e7e8n")
+	tt.store(42, ttData{score: -mateScore + 3, depth: 5, flag: lowerBound, move: m})
+	d, ok := tt.probe(42)
+	if !ok || d.score != -mateScore+3 || d.depth != 5 || d.flag != lowerBound || d.move != m {
+		t.Errorf("probe() expected the stored entry, got: %v, %v", d, ok)
+	}
+	if _, ok = tt.probe(42 + ttSize); ok {
+		t.Errorf("probe() expected a miss for another key in the same slot")
+	}
+}
+
+func playMoves(t *testing.T, moves []string) *game.Game {
+	g := game.StartGame()
+	for _, notation := range moves {
+		m, err := game.ParseMove(notation)
+		if err != nil {
+			t.Fatalf("ParseMove(%v) error: %v", notation, err)
+		}
+		if _, err = g.Play(m); err != nil {
+			t.Fatalf("Play(%v) error: %v", notation, err)
+		}
+	}
+	return g
+}
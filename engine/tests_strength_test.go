@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"lets-go-chess/game"
+	"testing"
+)
+
+func TestSkillFromElo(t *testing.T) {
+	tests := []struct {
+		elo    int
+		eSkill int
+	}{
+		{elo: 0, eSkill: MinSkill},
+		{elo: 800, eSkill: MinSkill},
+		{elo: 1600, eSkill: 10},
+		{elo: 2400, eSkill: MaxSkill},
+		{elo: 3000, eSkill: MaxSkill},
+	}
+	for _, test := range tests {
+		if skill := SkillFromElo(test.elo); skill != test.eSkill {
+			t.Errorf("SkillFromElo(%d) expected %d, got: %d", test.elo, test.eSkill, skill)
+		}
+	}
+}
+
+func TestWeakenedSearchIsReproducible(t *testing.T) {
+	g := playMoves(t, []string{"e2e4", "e7e5", "g1f3"})
+	for _, skill := range []int{MinSkill, 10} {
+		first, err := Search(g, Limits{Skill: skill, Seed: 7})
+		if err != nil {
+			t.Fatalf("Search() at skill %d error: %v", skill, err)
+		}
+		if m, _ := Search(g, Limits{Skill: skill, Seed: 7}); m != first {
+			t.Errorf("Search() at skill %d expected the same move %v for the same seed, got: %v", skill, first, m)
+		}
+		if _, err = g.Clone().Play(first); err != nil {
+			t.Errorf("Search() at skill %d returned illegal move %v: %v", skill, first, err)
+		}
+	}
+}
+
+func TestWeakenedSearchKeepsMate(t *testing.T) {
+	g := playMoves(t, []string{"e2e4", "e7e5", "f1c4", "b8c6", "d1h5", "g8f6"})
+	for seed := uint64(1); seed <= 10; seed++ {
+		if m, _ := Search(g, Limits{Skill: MinSkill, Seed: seed}); m.String() != "h5f7" {
+			t.Errorf("Search() at skill %d with seed %d expected h5f7, got: %v", MinSkill, seed, m)
+		}
+	}
+}
+
+func TestSkillChooseAvoidsMateAndBigLosses(t *testing.T) {
+	moves := make([]game.Move, 4)
+	for i := range moves {
+		moves[i] = game.Move{From: game.Position{X: i + 1, Y: 2}, To: game.Position{X: i + 1, Y: 3}}
+	}
+	scores := []int{50, 20, -mateScore + 2, -500}
+	sk := skill{margin: 100, mistakeChance: 1}
+	picked := make(map[game.Move]bool)
+	for seed := uint64(1); seed <= 200; seed++ {
+		picked[sk.choose(moves, scores, newRand(seed))] = true
+	}
+	if !picked[moves[0]] || !picked[moves[1]] {
+		t.Errorf("choose() expected both moves within the margin, got: %v", picked)
+	}
+	if picked[moves[2]] || picked[moves[3]] {
+		t.Errorf("choose() expected no mated or losing move, got: %v", picked)
+	}
+}
@@ -0,0 +1,16 @@
+package engine
+
+import (
+	"lets-go-chess/book"
+	"lets-go-chess/game"
+)
+
+// Think picks the move to play: a move from the configured opening book while
+// the game is still in it, otherwise the search result.
+func Think(g *game.Game, limits Limits) (game.Move, error) {
+	if m, ok := book.Default().Pick(g, book.ConfiguredSelection()); ok {
+		stats.bookMoves.Add(1)
+		return m, nil
+	}
+	return Search(g, limits)
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"lets-go-chess/game"
+	"sync/atomic"
+)
+
+const ttSize = 1 << 18
+
+type ttFlag uint64
+
+const (
+	exact ttFlag = iota
+	lowerBound
+	upperBound
+)
+
+// ttEntry is stored as two words. The key word holds key^data, so a reader
+// racing with a writer sees a mismatching key and ignores the entry instead
+// of using torn data. This keeps the table lock free.
+type ttEntry struct {
+	key  atomic.Uint64
+	data atomic.Uint64
+}
+
+type transpositionTable struct {
+	entries []ttEntry
+}
+
+type ttData struct {
+	score int
+	depth int
+	flag  ttFlag
+	move  game.Move
+}
+
+func newTranspositionTable() *transpositionTable {
+	return &transpositionTable{entries: make([]ttEntry, ttSize)}
+}
+
+func (t *transpositionTable) probe(key uint64) (ttData, bool) {
+	e := &t.entries[key%ttSize]
+	data := e.data.Load()
+	if e.key.Load()^data != key {
+		return ttData{}, false
+	}
+	return unpack(data), true
+}
+
+func (t *transpositionTable) store(key uint64, d ttData) {
+	e := &t.entries[key%ttSize]
+	data := pack(d)
+	e.data.Store(data)
+	e.key.Store(key ^ data)
+}
+
+// pack lays out the entry as: score 32 bits, depth 8 bits, flag 2 bits and
+// the move in the remaining bits.
+func pack(d ttData) uint64 {
+	return uint64(uint32(int32(d.score))) |
+		uint64(d.depth&0xff)<<32 |
+		uint64(d.flag&3)<<40 |
+		encodeMove(d.move)<<42
+}
+
+func unpack(data uint64) ttData {
+	return ttData{
+		score: int(int32(uint32(data))),
+		depth: int(data >> 32 & 0xff),
+		flag:  ttFlag(data >> 40 & 3),
+		move:  decodeMove(data >> 42),
+	}
+}
+
+// encodeMove packs the from and to squares in six bits each and the
+// promotion piece in three bits. Zero means no move.
+func encodeMove(m game.Move) uint64 {
+	if m.From == (game.Position{}) {
+		return 0
+	}
+	square := func(p game.Position) uint64 {
+		return uint64((p.Y-1)*8 + p.X - 1)
+	}
+	var promotion uint64
+	switch m.Promotion.(type) {
+	case game.Knight:
+		promotion = 1
+	case game.Bishop:
+		promotion = 2
+	case game.Rook:
+		promotion = 3
+	case game.Queen:
+		promotion = 4
+	}
+	return 1 | square(m.From)<<1 | square(m.To)<<7 | promotion<<13
+}
+
+func decodeMove(data uint64) game.Move {
+	if data&1 == 0 {
+		return game.Move{}
+	}
+	position := func(sq uint64) game.Position {
+		return game.Position{X: int(sq%8) + 1, Y: int(sq/8) + 1}
+	}
+	m := game.Move{From: position(data >> 1 & 63), To: position(data >> 7 & 63)}
+	switch data >> 13 & 7 {
+	case 1:
+		m.Promotion = game.Knight{}
+	case 2:
+		m.Promotion = game.Bishop{}
+	case 3:
+		m.Promotion = game.Rook{}
+	case 4:
+		m.Promotion = game.Queen{}
+	}
+	return m
+}
@@ -2,8 +2,14 @@ package game
 
 import "fmt"
 
+// DrawConsoleBoard prints the board as white sees it, files a-h from left
+// to right and rank 8 on top.
 func DrawConsoleBoard(field Board) {
-	for y := 0; y <= 8; y++ {
+	for row := 0; row <= 8; row++ {
+		y := 0
+		if row > 0 {
+			y = 9 - row
+		}
 		for x := 0; x <= 8; x++ {
 			if x == 0 || y == 0 {
 				drawNumber(x, y)
@@ -22,11 +28,11 @@ func drawNumber(x, y int) {
 		return
 	}
 	if y == 0 {
-		fmt.Printf(" %v ", x)
+		fmt.Printf(" %c ", 'a'+x-1)
 		return
 	}
 	if x == 0 {
-		fmt.Printf(" %c ", 'a'+y-1)
+		fmt.Printf(" %v ", y)
 		return
 	}
 }
@@ -0,0 +1,50 @@
+package game
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FEN returns the position in Forsyth-Edwards Notation.
+func (g *Game) FEN() string {
+	var b strings.Builder
+	for y := 8; y >= 1; y-- {
+		empty := 0
+		for x := 1; x <= 8; x++ {
+			figure := g.Field.Cells[Position{X: x, Y: y}]
+			if figure == nil || figure.Mover == nil {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				b.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			letter := PieceLetter(figure.Mover)
+			if !figure.IsWhite {
+				letter = strings.ToLower(letter)
+			}
+			b.WriteString(letter)
+		}
+		if empty > 0 {
+			b.WriteString(strconv.Itoa(empty))
+		}
+		if y > 1 {
+			b.WriteByte('/')
+		}
+	}
+	side := " b "
+	if g.IsWhiteMove {
+		side = " w "
+	}
+	b.WriteString(side)
+	b.WriteString(g.CastlingRights().String())
+	enPassant := "-"
+	if square, ok := g.EnPassantSquare(); ok {
+		enPassant = square.String()
+	}
+	b.WriteString(" " + enPassant)
+	b.WriteString(" " + strconv.Itoa(g.HalfmoveClock()))
+	b.WriteString(" " + strconv.Itoa(g.FullmoveNumber()))
+	return b.String()
+}
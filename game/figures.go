@@ -10,6 +10,7 @@ const (
 	EnPassant
 	ShortCastling
 	LongCastling
+	Promotion
 )
 
 type Situation int
@@ -47,7 +48,7 @@ func (k King) canMove(field Board, from, to Position, situation Situation) (ok b
 		if ok {
 			board := k.move(field, from, to, move)
 			kingPos := findKing(board, figure.IsWhite)
-			ok = !isFigureInThreat(board, kingPos, situation)
+			ok = isKingCapture(field, to) || !isFigureInThreat(board, kingPos, situation)
 		}
 	}()
 	isRookAndDidNotMove := func(pos Position) bool {
@@ -137,7 +138,7 @@ func (q Queen) canMove(field Board, from, to Position, situation Situation) (ok
 		if ok {
 			board := q.move(field, from, to, move)
 			kingPos := findKing(board, field.Cells[from].IsWhite)
-			ok = !isFigureInThreat(board, kingPos, situation)
+			ok = isKingCapture(field, to) || !isFigureInThreat(board, kingPos, situation)
 		}
 	}()
 	bishopOk, _ := q.bishop.canMove(field, from, to, situation)
@@ -159,7 +160,7 @@ func (r Rook) canMove(field Board, from, to Position, situation Situation) (ok b
 		if ok {
 			board := r.move(field, from, to, move)
 			kingPos := findKing(board, field.Cells[from].IsWhite)
-			ok = !isFigureInThreat(board, kingPos, situation)
+			ok = isKingCapture(field, to) || !isFigureInThreat(board, kingPos, situation)
 		}
 	}()
 	if deltaX != 0 && deltaY != 0 {
@@ -206,7 +207,7 @@ func (b Bishop) canMove(field Board, from, to Position, situation Situation) (ok
 		if ok {
 			board := b.move(field, from, to, move)
 			kingPos := findKing(board, field.Cells[from].IsWhite)
-			ok = !isFigureInThreat(board, kingPos, situation)
+			ok = isKingCapture(field, to) || !isFigureInThreat(board, kingPos, situation)
 		}
 	}()
 	if math.Abs(float64(deltaX)) == math.Abs(float64(deltaY)) {
@@ -251,7 +252,7 @@ func (k Knight) canMove(field Board, from, to Position, situation Situation) (ok
 		if ok {
 			board := k.move(field, from, to, move)
 			kingPos := findKing(board, field.Cells[from].IsWhite)
-			ok = !isFigureInThreat(board, kingPos, situation)
+			ok = isKingCapture(field, to) || !isFigureInThreat(board, kingPos, situation)
 		}
 	}()
 	if isFightingEnemy(field, from, to) || isCellEmpty(field, to) {
@@ -280,15 +281,15 @@ func (p Pawn) canMove(field Board, from, to Position, situation Situation) (ok b
 		if ok {
 			board := p.move(field, from, to, move)
 			kingPos := findKing(board, figure.IsWhite)
-			ok = !isFigureInThreat(board, kingPos, situation)
+			ok = isKingCapture(field, to) || !isFigureInThreat(board, kingPos, situation)
 		}
 	}()
 	if figure.IsWhite {
 		if deltaY == 1 && deltaX == 0 && isCellEmpty(field, to) {
-			return true, None
+			return true, promotionOrNone(figure, to)
 		}
 		if (deltaX == 1 || deltaX == -1) && deltaY == 1 && isFightingEnemy(field, from, to) {
-			return true, None
+			return true, promotionOrNone(figure, to)
 		}
 		if (!figure.HasMoved && deltaY == 2 && deltaX == 0 && isCellEmpty(field, to) && field.Cells[Position{to.X, to.Y - 1}] == nil) {
 			return true, ReadyForEnPassant
@@ -299,10 +300,10 @@ func (p Pawn) canMove(field Board, from, to Position, situation Situation) (ok b
 		}
 	} else {
 		if deltaY == -1 && deltaX == 0 && isCellEmpty(field, to) {
-			return true, None
+			return true, promotionOrNone(figure, to)
 		}
 		if (deltaX == 1 || deltaX == -1) && deltaY == -1 && isFightingEnemy(field, from, to) {
-			return true, None
+			return true, promotionOrNone(figure, to)
 		}
 		if (!figure.HasMoved && deltaY == -2 && deltaX == 0 && isCellEmpty(field, to) && field.Cells[Position{to.X, to.Y + 1}] == nil) {
 			return true, ReadyForEnPassant
@@ -326,5 +327,15 @@ func (p Pawn) move(field Board, from, to Position, move MoveDetails) Board {
 			field.Cells[Position{X: to.X, Y: to.Y + 1}] = nil
 		}
 	}
+	if move == Promotion {
+		field.Cells[to] = &Figure{IsWhite: figure.IsWhite, HasMoved: true, Mover: Queen{}}
+	}
 	return field
 }
+
+func promotionOrNone(figure *Figure, to Position) MoveDetails {
+	if figure.IsWhite && to.Y == 8 || !figure.IsWhite && to.Y == 1 {
+		return Promotion
+	}
+	return None
+}
@@ -0,0 +1,62 @@
+package game
+
+// startingPieces is how many pieces of each kind a side starts with.
+var startingPieces = map[string]int{"P": 8, "N": 2, "B": 2, "R": 2, "Q": 1}
+
+// PieceValue returns the usual value of a piece in pawns, zero for the king.
+func PieceValue(mover Mover) int {
+	switch mover.(type) {
+	case Pawn:
+		return 1
+	case Knight, Bishop:
+		return 3
+	case Rook:
+		return 5
+	case Queen:
+		return 9
+	}
+	return 0
+}
+
+// Material returns the value of the pieces of a side.
+func (g *Game) Material(isWhite bool) int {
+	material := 0
+	for _, figure := range g.Field.Cells {
+		if figure != nil && figure.Mover != nil && figure.IsWhite == isWhite {
+			material += PieceValue(figure.Mover)
+		}
+	}
+	return material
+}
+
+// MaterialBalance is white's material minus black's.
+func (g *Game) MaterialBalance() int {
+	return g.Material(true) - g.Material(false)
+}
+
+// CapturedPieces returns the pieces a side has lost, most valuable first.
+// Promoted pieces beyond the starting set stand in for the promoted pawns.
+func (g *Game) CapturedPieces(isWhite bool) []Mover {
+	counts := make(map[string]int)
+	for _, figure := range g.Field.Cells {
+		if figure != nil && figure.Mover != nil && figure.IsWhite == isWhite {
+			counts[PieceLetter(figure.Mover)]++
+		}
+	}
+	missingPawns := startingPieces["P"] - counts["P"]
+	var captured []Mover
+	for _, mover := range []Mover{Queen{}, Rook{}, Bishop{}, Knight{}} {
+		letter := PieceLetter(mover)
+		missing := startingPieces[letter] - counts[letter]
+		if missing < 0 {
+			missingPawns += missing
+		}
+		for range missing {
+			captured = append(captured, mover)
+		}
+	}
+	for range missingPawns {
+		captured = append(captured, Pawn{})
+	}
+	return captured
+}
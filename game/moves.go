@@ -0,0 +1,84 @@
+package game
+
+// PromotionPieces lists the pieces a pawn may be promoted to.
+var PromotionPieces = []Mover{Queen{}, Rook{}, Bishop{}, Knight{}}
+
+// LegalMoves returns every legal move of the side to move. Promotions are
+// listed once per promotion piece.
+func (g *Game) LegalMoves() []Move {
+	var moves []Move
+	if g.Result != Ongoing {
+		return moves
+	}
+	player := g.PlayerBlack
+	if g.IsWhiteMove {
+		player = g.PlayerWhite
+	}
+	for _, from := range boardPositions() {
+		figure := g.Field.Cells[from]
+		if figure == nil || figure.IsWhite != g.IsWhiteMove {
+			continue
+		}
+		for _, to := range boardPositions() {
+			if from == to {
+				continue
+			}
+			canMove, details := figure.canMove(g.Field, from, to, player.Situation)
+			if !canMove {
+				continue
+			}
+			if details == Promotion {
+				for _, piece := range PromotionPieces {
+					moves = append(moves, Move{From: from, To: to, Promotion: piece})
+				}
+				continue
+			}
+			moves = append(moves, Move{From: from, To: to})
+		}
+	}
+	return moves
+}
+
+// boardPositions returns all cells in a stable order, so that callers
+// iterating over the board do not depend on map ordering.
+func boardPositions() []Position {
+	positions := make([]Position, 0, 64)
+	for y := 1; y <= 8; y++ {
+		for x := 1; x <= 8; x++ {
+			positions = append(positions, Position{X: x, Y: y})
+		}
+	}
+	return positions
+}
+
+// MoveInfo describes what a legal move does.
+type MoveInfo struct {
+	Move
+	Capture   bool
+	Castling  bool
+	EnPassant bool
+	Check     bool
+}
+
+// DescribeMove tells what the move of the side to move does, the move is
+// expected to be legal.
+func (g *Game) DescribeMove(m Move) MoveInfo {
+	info := MoveInfo{Move: m}
+	figure := g.Field.Cells[m.From]
+	if figure == nil {
+		return info
+	}
+	info.Capture = g.Field.Cells[m.To] != nil
+	switch figure.Mover.(type) {
+	case King:
+		info.Castling = m.To.X-m.From.X == 2 || m.From.X-m.To.X == 2
+	case Pawn:
+		info.EnPassant = m.From.X != m.To.X && g.Field.Cells[m.To] == nil
+		info.Capture = info.Capture || info.EnPassant
+	}
+	child := g.Clone()
+	if situation, err := child.Play(m); err == nil {
+		info.Check = situation == Check || situation == Checkmate
+	}
+	return info
+}
@@ -0,0 +1,100 @@
+package game
+
+import (
+	"errors"
+	"strings"
+)
+
+var InvalidNotation = errors.New("invalid notation")
+
+// String returns the square in algebraic notation, e.g. "e4".
+func (p Position) String() string {
+	if p.X < 1 || p.X > 8 || p.Y < 1 || p.Y > 8 {
+		return "-"
+	}
+	return string(rune('a'+p.X-1)) + string(rune('0'+p.Y))
+}
+
+// ParsePosition parses a square in algebraic notation, e.g. "e4".
+func ParsePosition(s string) (Position, error) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return Position{}, InvalidNotation
+	}
+	return Position{X: int(s[0]-'a') + 1, Y: int(s[1] - '0')}, nil
+}
+
+// String returns the move in coordinate notation, e.g. "e2e4" or "e7e8q".
+func (m Move) String() string {
+	s := m.From.String() + m.To.String()
+	if m.Promotion != nil {
+		s += strings.ToLower(PieceLetter(m.Promotion))
+	}
+	return s
+}
+
+// ParseMove parses a move in coordinate notation, e.g. "e2e4" or "e7e8q".
+func ParseMove(s string) (Move, error) {
+	if len(s) != 4 && len(s) != 5 {
+		return Move{}, InvalidNotation
+	}
+	from, err := ParsePosition(s[0:2])
+	if err != nil {
+		return Move{}, err
+	}
+	to, err := ParsePosition(s[2:4])
+	if err != nil {
+		return Move{}, err
+	}
+	m := Move{From: from, To: to}
+	if len(s) == 5 {
+		m.Promotion = MoverFromLetter(s[4:])
+		if m.Promotion == nil {
+			return Move{}, InvalidNotation
+		}
+		if _, isKing := m.Promotion.(King); isKing {
+			return Move{}, InvalidNotation
+		}
+		if _, isPawn := m.Promotion.(Pawn); isPawn {
+			return Move{}, InvalidNotation
+		}
+	}
+	return m, nil
+}
+
+// PieceLetter returns the upper case letter of the piece, e.g. "N" for a knight.
+func PieceLetter(mover Mover) string {
+	switch mover.(type) {
+	case Pawn:
+		return "P"
+	case Rook:
+		return "R"
+	case Knight:
+		return "N"
+	case Bishop:
+		return "B"
+	case Queen:
+		return "Q"
+	case King:
+		return "K"
+	}
+	return ""
+}
+
+// MoverFromLetter returns the piece for a letter in either case, or nil.
+func MoverFromLetter(letter string) Mover {
+	switch strings.ToUpper(letter) {
+	case "P":
+		return Pawn{}
+	case "R":
+		return Rook{}
+	case "N":
+		return Knight{}
+	case "B":
+		return Bishop{}
+	case "Q":
+		return Queen{}
+	case "K":
+		return King{}
+	}
+	return nil
+}
@@ -7,10 +7,28 @@ type Game struct {
 	PlayerWhite    *Player
 	PlayerBlack    *Player
 	IsWhiteMove    bool
+	Result         Result
+	Moves          []Move
 	enPassantWhite *Figure
 	enPassantBlack *Figure
+	history        []*Game
 }
 
+type Move struct {
+	From      Position
+	To        Position
+	Promotion Mover
+}
+
+type Result int
+
+const (
+	Ongoing Result = iota
+	WhiteWon
+	BlackWon
+	Draw
+)
+
 type Player struct {
 	IsWhite bool
 	Situation
@@ -25,6 +43,8 @@ var (
 	ToOutOfBounds      = errors.New("to out of bounds")
 	MoveRulesViolation = errors.New("move rules violation")
 	WrongColor         = errors.New("wrong color")
+	GameOver           = errors.New("game over")
+	NothingToUndo      = errors.New("nothing to undo")
 )
 
 func StartGame() *Game {
@@ -37,20 +57,108 @@ func StartGame() *Game {
 }
 
 func (g *Game) NextMove(from, to Position) (Situation, error) {
+	return g.Play(Move{From: from, To: to})
+}
+
+// Play applies the move for the side to move. A pawn reaching the last rank
+// is promoted to move.Promotion, or to a queen when it is not set.
+func (g *Game) Play(m Move) (Situation, error) {
+	if g.Result != Ongoing {
+		return Continue, GameOver
+	}
 	var player *Player
 	if g.IsWhiteMove {
 		player = g.PlayerWhite
 	} else {
 		player = g.PlayerBlack
 	}
-	situation, err := g.move(from, to, player)
-	if err == nil {
-		g.IsWhiteMove = !g.IsWhiteMove
+	snapshot := g.snapshot()
+	situation, err := g.move(m.From, m.To, m.Promotion, player)
+	if err != nil {
+		g.restore(snapshot)
+		return situation, err
+	}
+	g.history = append(g.history, snapshot)
+	g.Moves = append(g.Moves, m)
+	g.IsWhiteMove = !g.IsWhiteMove
+	switch situation {
+	case Checkmate:
+		if player.IsWhite {
+			g.Result = WhiteWon
+		} else {
+			g.Result = BlackWon
+		}
+	case Stalemate:
+		g.Result = Draw
 	}
-	return situation, err
+	return situation, nil
+}
+
+// Undo takes back the last move, restoring castling and en passant state.
+func (g *Game) Undo() error {
+	if len(g.history) == 0 {
+		return NothingToUndo
+	}
+	last := len(g.history) - 1
+	snapshot := g.history[last]
+	history := g.history[:last]
+	moves := g.Moves[:last]
+	g.restore(snapshot.snapshot())
+	g.history = history
+	g.Moves = moves
+	return nil
 }
 
-func (g *Game) move(from, to Position, player *Player) (Situation, error) {
+// Finish ends the game with the given result, e.g. after a resignation.
+func (g *Game) Finish(result Result) {
+	g.Result = result
+}
+
+// Clone returns an independent copy of the game which can be moved on
+// without affecting the original.
+func (g *Game) Clone() *Game {
+	c := g.snapshot()
+	c.history = append([]*Game(nil), g.history...)
+	c.Moves = append([]Move(nil), g.Moves...)
+	return c
+}
+
+func (g *Game) snapshot() *Game {
+	s := &Game{
+		Field:       Board{Cells: make(map[Position]*Figure, len(g.Field.Cells))},
+		PlayerWhite: &Player{IsWhite: true, Situation: g.PlayerWhite.Situation},
+		PlayerBlack: &Player{IsWhite: false, Situation: g.PlayerBlack.Situation},
+		IsWhiteMove: g.IsWhiteMove,
+		Result:      g.Result,
+	}
+	for pos, figure := range g.Field.Cells {
+		if figure == nil {
+			s.Field.Cells[pos] = nil
+			continue
+		}
+		f := *figure
+		s.Field.Cells[pos] = &f
+		if figure == g.enPassantWhite {
+			s.enPassantWhite = &f
+		}
+		if figure == g.enPassantBlack {
+			s.enPassantBlack = &f
+		}
+	}
+	return s
+}
+
+func (g *Game) restore(s *Game) {
+	g.Field = s.Field
+	g.PlayerWhite.Situation = s.PlayerWhite.Situation
+	g.PlayerBlack.Situation = s.PlayerBlack.Situation
+	g.IsWhiteMove = s.IsWhiteMove
+	g.Result = s.Result
+	g.enPassantWhite = s.enPassantWhite
+	g.enPassantBlack = s.enPassantBlack
+}
+
+func (g *Game) move(from, to Position, promotion Mover, player *Player) (Situation, error) {
 	figure := g.Field.Cells[from]
 	if figure == nil {
 		return Continue, InvalidFrom
@@ -83,6 +191,9 @@ func (g *Game) move(from, to Position, player *Player) (Situation, error) {
 	}
 	g.Field = figure.move(g.Field, from, to, moveDetails)
 	figure.HasMoved = true
+	if moveDetails == Promotion && promotion != nil {
+		g.Field.Cells[to].Mover = promotion
+	}
 	situation := analyzeSituation(g.Field, player.IsWhite, player.Situation)
 	if player.IsWhite {
 		g.PlayerBlack.Situation = situation
@@ -0,0 +1,179 @@
+package game
+
+import (
+	"errors"
+	"strings"
+)
+
+var AmbiguousMove = errors.New("ambiguous move")
+
+// SAN returns the legal move of the side to move in standard algebraic
+// notation, e.g. "Nf3", "exd5", "e8=Q+" or "O-O".
+func (g *Game) SAN(m Move) string {
+	figure := g.Field.Cells[m.From]
+	if figure == nil {
+		return m.String()
+	}
+	info := g.DescribeMove(m)
+	var b strings.Builder
+	switch letter := PieceLetter(figure.Mover); {
+	case info.Castling && m.To.X > m.From.X:
+		b.WriteString("O-O")
+	case info.Castling:
+		b.WriteString("O-O-O")
+	default:
+		if letter != "P" {
+			b.WriteString(letter)
+			b.WriteString(g.disambiguation(m, letter))
+		} else if info.Capture {
+			b.WriteString(m.From.String()[:1])
+		}
+		if info.Capture {
+			b.WriteByte('x')
+		}
+		b.WriteString(m.To.String())
+		if letter == "P" && (m.To.Y == 1 || m.To.Y == 8) {
+			promotion := m.Promotion
+			if promotion == nil {
+				promotion = Queen{}
+			}
+			b.WriteString("=" + PieceLetter(promotion))
+		}
+	}
+	child := g.Clone()
+	switch situation, _ := child.Play(m); situation {
+	case Checkmate:
+		b.WriteByte('#')
+	case Check:
+		b.WriteByte('+')
+	}
+	return b.String()
+}
+
+// disambiguation returns the file, the rank or the square of the origin when
+// another piece of the same kind can move to the same square.
+func (g *Game) disambiguation(m Move, letter string) string {
+	var sameFile, sameRank, ambiguous bool
+	for _, other := range g.LegalMoves() {
+		if other.To != m.To || other.From == m.From || PieceLetter(g.Field.Cells[other.From].Mover) != letter {
+			continue
+		}
+		ambiguous = true
+		sameFile = sameFile || other.From.X == m.From.X
+		sameRank = sameRank || other.From.Y == m.From.Y
+	}
+	from := m.From.String()
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return from[:1]
+	case !sameRank:
+		return from[1:]
+	}
+	return from
+}
+
+// LastMoveSAN returns the last move in standard algebraic notation, empty
+// before the first move.
+func (g *Game) LastMoveSAN() string {
+	if len(g.history) == 0 {
+		return ""
+	}
+	return g.history[len(g.history)-1].SAN(g.Moves[len(g.Moves)-1])
+}
+
+// ParseSAN finds the legal move of the side to move written in standard
+// algebraic notation. Check marks and annotations are ignored, so are
+// superfluous disambiguations, a missing promotion piece means a queen.
+func (g *Game) ParseSAN(s string) (Move, error) {
+	if g.Result != Ongoing {
+		return Move{}, GameOver
+	}
+	s = strings.TrimRight(s, "+#!?")
+	switch s {
+	case "O-O", "0-0":
+		return g.castling(true)
+	case "O-O-O", "0-0-0":
+		return g.castling(false)
+	}
+	letter := "P"
+	if s != "" && strings.ContainsRune("KQRBN", rune(s[0])) {
+		letter, s = s[:1], s[1:]
+	}
+	var promotion Mover
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		promotion = MoverFromLetter(s[i+1:])
+		if promotion == nil || letter != "P" {
+			return Move{}, InvalidNotation
+		}
+		s = s[:i]
+	}
+	if len(s) < 2 {
+		return Move{}, InvalidNotation
+	}
+	to, err := ParsePosition(s[len(s)-2:])
+	if err != nil {
+		return Move{}, err
+	}
+	origin := strings.TrimSuffix(s[:len(s)-2], "x")
+	if len(origin) > 2 {
+		return Move{}, InvalidNotation
+	}
+	fromFile, fromRank := 0, 0
+	for _, c := range origin {
+		switch {
+		case c >= 'a' && c <= 'h' && fromFile == 0 && fromRank == 0:
+			fromFile = int(c-'a') + 1
+		case c >= '1' && c <= '8' && fromRank == 0:
+			fromRank = int(c - '0')
+		default:
+			return Move{}, InvalidNotation
+		}
+	}
+
+	var found []Move
+	for _, m := range g.LegalMoves() {
+		switch {
+		case m.To != to || PieceLetter(g.Field.Cells[m.From].Mover) != letter:
+		case fromFile != 0 && m.From.X != fromFile, fromRank != 0 && m.From.Y != fromRank:
+		case m.Promotion != nil && promotion == nil && PieceLetter(m.Promotion) != "Q":
+		case m.Promotion != nil && promotion != nil && PieceLetter(m.Promotion) != PieceLetter(promotion):
+		case g.DescribeMove(m).Castling:
+		default:
+			found = append(found, m)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Move{}, MoveRulesViolation
+	case 1:
+		return found[0], nil
+	}
+	return Move{}, AmbiguousMove
+}
+
+func (g *Game) castling(kingside bool) (Move, error) {
+	for _, m := range g.LegalMoves() {
+		if g.DescribeMove(m).Castling && (m.To.X > m.From.X) == kingside {
+			return m, nil
+		}
+	}
+	return Move{}, MoveRulesViolation
+}
+
+// ParseNotation reads a move of the side to move given in coordinate
+// notation, e.g. "e2e4", or in standard algebraic notation, e.g. "Nf3". A
+// pawn move to the last rank without a promotion piece promotes to a queen.
+func (g *Game) ParseNotation(s string) (Move, error) {
+	m, err := ParseMove(s)
+	if err != nil {
+		return g.ParseSAN(s)
+	}
+	if figure := g.Field.Cells[m.From]; figure != nil && m.Promotion == nil && (m.To.Y == 1 || m.To.Y == 8) {
+		if _, isPawn := figure.Mover.(Pawn); isPawn {
+			m.Promotion = Queen{}
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,107 @@
+package game
+
+// CastlingRights tells which castlings are still possible later in the game,
+// i.e. the king and the rook involved have not moved yet.
+type CastlingRights struct {
+	WhiteKingside  bool
+	WhiteQueenside bool
+	BlackKingside  bool
+	BlackQueenside bool
+}
+
+// String returns the rights the way FEN writes them, e.g. "KQkq" or "-".
+func (c CastlingRights) String() string {
+	s := ""
+	if c.WhiteKingside {
+		s += "K"
+	}
+	if c.WhiteQueenside {
+		s += "Q"
+	}
+	if c.BlackKingside {
+		s += "k"
+	}
+	if c.BlackQueenside {
+		s += "q"
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// CastlingRights returns the castling rights of both sides.
+func (g *Game) CastlingRights() CastlingRights {
+	return CastlingRights{
+		WhiteKingside:  canStillCastle(g.Field, Position{X: 5, Y: 1}, Position{X: 8, Y: 1}),
+		WhiteQueenside: canStillCastle(g.Field, Position{X: 5, Y: 1}, Position{X: 1, Y: 1}),
+		BlackKingside:  canStillCastle(g.Field, Position{X: 5, Y: 8}, Position{X: 8, Y: 8}),
+		BlackQueenside: canStillCastle(g.Field, Position{X: 5, Y: 8}, Position{X: 1, Y: 8}),
+	}
+}
+
+func canStillCastle(field Board, kingPos, rookPos Position) bool {
+	king := field.Cells[kingPos]
+	rook := field.Cells[rookPos]
+	if king == nil || rook == nil || king.HasMoved || rook.HasMoved || king.IsWhite != rook.IsWhite {
+		return false
+	}
+	_, isKing := king.Mover.(King)
+	_, isRook := rook.Mover.(Rook)
+	return isKing && isRook && king.IsWhite == (kingPos.Y == 1)
+}
+
+// EnPassantSquare returns the square a pawn has just skipped with its double
+// step, whether or not a capture there is possible.
+func (g *Game) EnPassantSquare() (Position, bool) {
+	for pos, figure := range g.Field.Cells {
+		if figure == nil || !figure.IsVulnerableForEnPassant || figure.IsWhite == g.IsWhiteMove {
+			continue
+		}
+		if figure.IsWhite {
+			return Position{X: pos.X, Y: pos.Y - 1}, true
+		}
+		return Position{X: pos.X, Y: pos.Y + 1}, true
+	}
+	return Position{}, false
+}
+
+// HalfmoveClock counts the moves since the last capture or pawn move.
+func (g *Game) HalfmoveClock() int {
+	clock := 0
+	for i := len(g.Moves) - 1; i >= 0; i-- {
+		before := g.history[i].Field
+		if _, isPawn := before.Cells[g.Moves[i].From].Mover.(Pawn); isPawn || before.Cells[g.Moves[i].To] != nil {
+			break
+		}
+		clock++
+	}
+	return clock
+}
+
+// FullmoveNumber is the number of the current move, starting at 1 and
+// incremented after each black move.
+func (g *Game) FullmoveNumber() int {
+	plies := len(g.Moves)
+	if len(g.history) > 0 && !g.history[0].IsWhiteMove {
+		plies++
+	}
+	return plies/2 + 1
+}
+
+// Situation returns the situation of the side to move.
+func (g *Game) Situation() Situation {
+	if g.IsWhiteMove {
+		return g.PlayerWhite.Situation
+	}
+	return g.PlayerBlack.Situation
+}
+
+// CheckedKing returns the square of the king of the side to move when it is
+// in check.
+func (g *Game) CheckedKing() (Position, bool) {
+	if situation := g.Situation(); situation != Check && situation != Checkmate {
+		return Position{}, false
+	}
+	return findKing(g.Field, g.IsWhiteMove), true
+}
@@ -160,3 +160,17 @@ func TestNextMoveStalemateKing(t *testing.T) {
 	}
 	test(tests, t)
 }
+
+func TestNextMoveCheckKingCanEscape(t *testing.T) {
+	g := StartGame()
+	for _, notation := range []string{"e2e4", "e7e5", "f1c4", "b8c6", "d1h5", "g8f6"} {
+		m, _ := ParseMove(notation)
+		if _, err := g.Play(m); err != nil {
+			t.Fatalf("Play(%v) error: %v", notation, err)
+		}
+	}
+	situation, err := g.NextMove(Position{3, 4}, Position{6, 7})
+	if err != nil || situation != Check {
+		t.Errorf("NextMove(c4, f7) expected situation: %v, got situation: %v, error: %v", Check, situation, err)
+	}
+}
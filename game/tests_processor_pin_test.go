@@ -0,0 +1,62 @@
+package game
+
+import "testing"
+
+// A pinned piece cannot move, but it still gives check: the king it attacks
+// would be taken before its own.
+func TestNextMoveCheckByPinnedPiece(t *testing.T) {
+	g := StartGame()
+	// the black knight on e5 is pinned to its king by the rook on e1 and
+	// checks the white king on f3
+	g.Field = createCustomField(map[Position]*Figure{
+		Position{6, 3}: {IsWhite: true, HasMoved: true, IsVulnerableForEnPassant: false, Mover: King{}},
+		Position{5, 1}: {IsWhite: true, HasMoved: true, IsVulnerableForEnPassant: false, Mover: Rook{}},
+		Position{8, 2}: {IsWhite: true, HasMoved: false, IsVulnerableForEnPassant: false, Mover: Pawn{}},
+		Position{5, 8}: {IsWhite: false, HasMoved: true, IsVulnerableForEnPassant: false, Mover: King{}},
+		Position{5, 5}: {IsWhite: false, HasMoved: true, IsVulnerableForEnPassant: false, Mover: Knight{}},
+	})
+	eField := createCustomField(map[Position]*Figure{
+		Position{6, 3}: {IsWhite: true, HasMoved: true, IsVulnerableForEnPassant: false, Mover: King{}},
+		Position{5, 1}: {IsWhite: true, HasMoved: true, IsVulnerableForEnPassant: false, Mover: Rook{}},
+		Position{8, 2}: {IsWhite: true, HasMoved: false, IsVulnerableForEnPassant: false, Mover: Pawn{}},
+		Position{5, 8}: {IsWhite: false, HasMoved: true, IsVulnerableForEnPassant: false, Mover: King{}},
+		Position{5, 5}: {IsWhite: false, HasMoved: true, IsVulnerableForEnPassant: false, Mover: Knight{}},
+	})
+	eEscapedField := createCustomField(map[Position]*Figure{
+		Position{5, 2}: {IsWhite: true, HasMoved: true, IsVulnerableForEnPassant: false, Mover: King{}},
+		Position{5, 1}: {IsWhite: true, HasMoved: true, IsVulnerableForEnPassant: false, Mover: Rook{}},
+		Position{8, 2}: {IsWhite: true, HasMoved: false, IsVulnerableForEnPassant: false, Mover: Pawn{}},
+		Position{5, 8}: {IsWhite: false, HasMoved: true, IsVulnerableForEnPassant: false, Mover: King{}},
+		Position{5, 5}: {IsWhite: false, HasMoved: true, IsVulnerableForEnPassant: false, Mover: Knight{}},
+	})
+
+	tests := []OneMoveTestCase{
+		{
+			// ignoring the check
+			from:       Position{8, 2},
+			to:         Position{8, 3},
+			g:          g,
+			eField:     eField,
+			eSituation: Continue,
+			eError:     MoveRulesViolation,
+		},
+		{
+			// stepping into another square the pinned knight attacks
+			from:       Position{6, 3},
+			to:         Position{7, 4},
+			g:          g,
+			eField:     eField,
+			eSituation: Continue,
+			eError:     MoveRulesViolation,
+		},
+		{
+			from:       Position{6, 3},
+			to:         Position{5, 2},
+			g:          g,
+			eField:     eEscapedField,
+			eSituation: Continue,
+			eError:     nil,
+		},
+	}
+	test(tests, t)
+}
@@ -0,0 +1,102 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUndoRestoresEnPassantAndCastling(t *testing.T) {
+	g := StartGame()
+	moves := []string{"e2e4", "a7a6", "e4e5", "d7d5", "g1f3", "a6a5", "f1e2", "a5a4"}
+	for _, notation := range moves {
+		m, err := ParseMove(notation)
+		if err != nil {
+			t.Fatalf("ParseMove(%v) error: %v", notation, err)
+		}
+		if _, err = g.Play(m); err != nil {
+			t.Fatalf("Play(%v) error: %v", notation, err)
+		}
+	}
+	before := g.Clone()
+	castling, _ := ParseMove("e1g1")
+	if _, err := g.Play(castling); err != nil {
+		t.Fatalf("Play(e1g1) error: %v", err)
+	}
+	if err := g.Undo(); err != nil {
+		t.Fatalf("Undo() error: %v", err)
+	}
+	isExpected, wrongPos, wrongFigure := isAllFiguresExpected(g.Field, before.Field)
+	if !isExpected {
+		t.Errorf("Undo() wrong figure in wrong place: %v, %v", wrongPos, wrongFigure)
+	}
+	if !g.IsWhiteMove || len(g.Moves) != len(moves) {
+		t.Errorf("Undo() expected white to move after %d moves, got white: %v, moves: %d", len(moves), g.IsWhiteMove, len(g.Moves))
+	}
+	if _, err := g.Play(castling); err != nil {
+		t.Errorf("Play(e1g1) after undo error: %v", err)
+	}
+
+	g = StartGame()
+	for _, notation := range []string{"e2e4", "a7a6", "e4e5", "d7d5"} {
+		m, _ := ParseMove(notation)
+		g.Play(m)
+	}
+	if err := g.Undo(); err != nil {
+		t.Fatalf("Undo() error: %v", err)
+	}
+	for _, notation := range []string{"d7d5", "e5d6"} {
+		m, _ := ParseMove(notation)
+		if _, err := g.Play(m); err != nil {
+			t.Errorf("Play(%v) after undo error: %v", notation, err)
+		}
+	}
+}
+
+func TestUndoNothingToUndo(t *testing.T) {
+	g := StartGame()
+	if err := g.Undo(); !errors.Is(err, NothingToUndo) {
+		t.Errorf("Undo() expected error: %v, got: %v", NothingToUndo, err)
+	}
+}
+
+func TestPromotion(t *testing.T) {
+	g := StartGame()
+	g.Field = createCustomField(map[Position]*Figure{
+		Position{1, 1}: {IsWhite: true, HasMoved: true, IsVulnerableForEnPassant: false, Mover: King{}},
+		Position{8, 8}: {IsWhite: false, HasMoved: true, IsVulnerableForEnPassant: false, Mover: King{}},
+		Position{2, 7}: {IsWhite: true, HasMoved: true, IsVulnerableForEnPassant: false, Mover: Pawn{}},
+	})
+	eField := createCustomField(map[Position]*Figure{
+		Position{1, 1}: {IsWhite: true, HasMoved: true, IsVulnerableForEnPassant: false, Mover: King{}},
+		Position{8, 8}: {IsWhite: false, HasMoved: true, IsVulnerableForEnPassant: false, Mover: King{}},
+		Position{2, 8}: {IsWhite: true, HasMoved: true, IsVulnerableForEnPassant: false, Mover: Knight{}},
+	})
+	if got := len(g.LegalMoves()); got != 4+3 {
+		t.Errorf("LegalMoves() expected 7 moves, got: %d", got)
+	}
+	m, _ := ParseMove("b7b8n")
+	situation, err := g.Play(m)
+	if err != nil || situation != Continue {
+		t.Fatalf("Play(b7b8n) expected situation: %v, got situation: %v, error: %v", Continue, situation, err)
+	}
+	isExpected, wrongPos, wrongFigure := isAllFiguresExpected(g.Field, eField)
+	if !isExpected {
+		t.Errorf("Play(b7b8n) wrong figure in wrong place: %v, %v", wrongPos, wrongFigure)
+	}
+}
+
+func TestGameOver(t *testing.T) {
+	g := StartGame()
+	for _, notation := range []string{"f2f3", "e7e5", "g2g4", "d8h4"} {
+		m, _ := ParseMove(notation)
+		if _, err := g.Play(m); err != nil {
+			t.Fatalf("Play(%v) error: %v", notation, err)
+		}
+	}
+	if g.Result != BlackWon {
+		t.Errorf("expected result: %v, got: %v", BlackWon, g.Result)
+	}
+	if _, err := g.NextMove(Position{1, 2}, Position{1, 3}); !errors.Is(err, GameOver) {
+		t.Errorf("NextMove after checkmate expected error: %v, got: %v", GameOver, err)
+	}
+}
@@ -0,0 +1,102 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSAN(t *testing.T) {
+	tests := []struct {
+		moves []string
+		eSAN  []string
+	}{
+		{
+			moves: []string{"e2e4", "d7d5", "e4d5", "d8d5", "b1c3", "d5e5", "g1e2", "c8g4"},
+			eSAN:  []string{"e4", "d5", "exd5", "Qxd5", "Nc3", "Qe5+", "Nge2", "Bg4"},
+		},
+		{
+			moves: []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "f8c5", "e1g1"},
+			eSAN:  []string{"e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5", "O-O"},
+		},
+		{
+			moves: []string{"f2f3", "e7e5", "g2g4", "d8h4"},
+			eSAN:  []string{"f3", "e5", "g4", "Qh4#"},
+		},
+	}
+	for _, test := range tests {
+		byUci, bySan := StartGame(), StartGame()
+		for i, notation := range test.moves {
+			m, _ := ParseMove(notation)
+			if san := byUci.SAN(m); san != test.eSAN[i] {
+				t.Errorf("SAN(%v) expected %v, got: %v", notation, test.eSAN[i], san)
+			}
+			byUci.Play(m)
+			parsed, err := bySan.ParseNotation(test.eSAN[i])
+			if err != nil || parsed.String() != notation {
+				t.Fatalf("ParseNotation(%v) expected %v, got: %v, %v", test.eSAN[i], notation, parsed, err)
+			}
+			bySan.Play(parsed)
+			if san := bySan.LastMoveSAN(); san != test.eSAN[i] {
+				t.Errorf("LastMoveSAN() expected %v, got: %v", test.eSAN[i], san)
+			}
+		}
+	}
+}
+
+func TestParseSANPromotion(t *testing.T) {
+	g := &Game{
+		Field:       Board{Cells: make(map[Position]*Figure)},
+		PlayerWhite: &Player{IsWhite: true},
+		PlayerBlack: &Player{IsWhite: false},
+		IsWhiteMove: true,
+	}
+	for _, pos := range boardPositions() {
+		g.Field.Cells[pos] = nil
+	}
+	g.Field.Cells[Position{X: 5, Y: 1}] = &Figure{IsWhite: true, HasMoved: true, Mover: King{}}
+	g.Field.Cells[Position{X: 5, Y: 7}] = &Figure{IsWhite: true, HasMoved: true, Mover: Pawn{}}
+	g.Field.Cells[Position{X: 8, Y: 8}] = &Figure{IsWhite: false, HasMoved: true, Mover: King{}}
+	tests := []struct {
+		notation string
+		eMove    string
+		eSAN     string
+	}{
+		{notation: "e8=N", eMove: "e7e8n", eSAN: "e8=N"},
+		{notation: "e8", eMove: "e7e8q", eSAN: "e8=Q+"},
+		{notation: "e7e8", eMove: "e7e8q", eSAN: "e8=Q+"},
+		{notation: "e7e8r", eMove: "e7e8r", eSAN: "e8=R+"},
+	}
+	for _, test := range tests {
+		m, err := g.ParseNotation(test.notation)
+		if err != nil || m.String() != test.eMove {
+			t.Errorf("ParseNotation(%v) expected %v, got: %v, %v", test.notation, test.eMove, m, err)
+			continue
+		}
+		if san := g.SAN(m); san != test.eSAN {
+			t.Errorf("SAN(%v) expected %v, got: %v", m, test.eSAN, san)
+		}
+	}
+}
+
+func TestParseSANErrors(t *testing.T) {
+	g := StartGame()
+	for _, notation := range []string{"e2e4", "d7d5", "e4d5", "d8d5", "b1c3", "d5e5"} {
+		m, _ := ParseMove(notation)
+		g.Play(m)
+	}
+	tests := []struct {
+		notation string
+		eError   error
+	}{
+		{notation: "Ne2", eError: AmbiguousMove},
+		{notation: "Ke2", eError: MoveRulesViolation},
+		{notation: "Nf3", eError: MoveRulesViolation},
+		{notation: "Zz9", eError: InvalidNotation},
+		{notation: "N", eError: InvalidNotation},
+	}
+	for _, test := range tests {
+		if _, err := g.ParseNotation(test.notation); !errors.Is(err, test.eError) {
+			t.Errorf("ParseNotation(%v) expected error: %v, got: %v", test.notation, test.eError, err)
+		}
+	}
+}
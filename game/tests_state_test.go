@@ -0,0 +1,117 @@
+package game
+
+import "testing"
+
+func TestGameState(t *testing.T) {
+	g := StartGame()
+	if rights := g.CastlingRights().String(); rights != "KQkq" {
+		t.Errorf("CastlingRights() expected KQkq, got: %v", rights)
+	}
+	for _, notation := range []string{"e2e4", "d7d5", "e4d5", "g8f6", "g1f3", "h8g8", "f3g1", "c7c5"} {
+		m, _ := ParseMove(notation)
+		if _, err := g.Play(m); err != nil {
+			t.Fatalf("Play(%v) error: %v", notation, err)
+		}
+	}
+	if square, ok := g.EnPassantSquare(); !ok || square.String() != "c6" {
+		t.Errorf("EnPassantSquare() expected c6, got: %v, %v", square, ok)
+	}
+	if clock := g.HalfmoveClock(); clock != 0 {
+		t.Errorf("HalfmoveClock() expected 0 after a pawn move, got: %d", clock)
+	}
+	m, _ := ParseMove("b1c3")
+	g.Play(m)
+	if square, ok := g.EnPassantSquare(); ok {
+		t.Errorf("EnPassantSquare() expected none, got: %v", square)
+	}
+	if rights := g.CastlingRights().String(); rights != "KQq" {
+		t.Errorf("CastlingRights() expected KQq, got: %v", rights)
+	}
+	if clock, number := g.HalfmoveClock(), g.FullmoveNumber(); clock != 1 || number != 5 {
+		t.Errorf("HalfmoveClock(), FullmoveNumber() expected 1, 5, got: %d, %d", clock, number)
+	}
+	if situation := g.Situation(); situation != Continue {
+		t.Errorf("Situation() expected %v, got: %v", Continue, situation)
+	}
+}
+
+func TestDescribeMove(t *testing.T) {
+	g := StartGame()
+	for _, notation := range []string{"e2e4", "d7d5", "e4e5", "f7f5", "g1f3", "g8f6", "f1c4", "b8c6"} {
+		m, _ := ParseMove(notation)
+		if _, err := g.Play(m); err != nil {
+			t.Fatalf("Play(%v) error: %v", notation, err)
+		}
+	}
+	tests := []struct {
+		move  string
+		eInfo MoveInfo
+	}{
+		{move: "e1g1", eInfo: MoveInfo{Castling: true}},
+		{move: "c4d5", eInfo: MoveInfo{Capture: true}},
+		{move: "e5f6", eInfo: MoveInfo{Capture: true}},
+		{move: "c4e6", eInfo: MoveInfo{}},
+		{move: "d2d3", eInfo: MoveInfo{}},
+	}
+	for _, test := range tests {
+		m, _ := ParseMove(test.move)
+		test.eInfo.Move = m
+		if info := g.DescribeMove(m); info != test.eInfo {
+			t.Errorf("DescribeMove(%v) expected %+v, got: %+v", test.move, test.eInfo, info)
+		}
+	}
+
+	g = StartGame()
+	for _, notation := range []string{"e2e4", "d7d5", "e4e5", "f7f5"} {
+		m, _ := ParseMove(notation)
+		g.Play(m)
+	}
+	m, _ := ParseMove("e5f6")
+	if info := g.DescribeMove(m); !info.EnPassant || !info.Capture {
+		t.Errorf("DescribeMove(e5f6) expected en passant capture, got: %+v", info)
+	}
+	m, _ = ParseMove("f1b5")
+	if info := g.DescribeMove(m); !info.Check || info.Capture {
+		t.Errorf("DescribeMove(f1b5) expected a quiet check, got: %+v", info)
+	}
+}
+
+func TestFEN(t *testing.T) {
+	g := StartGame()
+	if fen := g.FEN(); fen != "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1" {
+		t.Errorf("FEN() expected the start position, got: %v", fen)
+	}
+	for _, notation := range []string{"e2e4", "d7d5", "e4d5", "d8d5", "b1c3", "d5e5"} {
+		m, _ := ParseMove(notation)
+		g.Play(m)
+	}
+	if fen := g.FEN(); fen != "rnb1kbnr/ppp1pppp/8/4q3/8/2N5/PPPP1PPP/R1BQKBNR w KQkq - 2 4" {
+		t.Errorf("FEN() expected the position after Qe5+, got: %v", fen)
+	}
+	if square, ok := g.CheckedKing(); !ok || square.String() != "e1" {
+		t.Errorf("CheckedKing() expected e1, got: %v, %v", square, ok)
+	}
+	if balance := g.MaterialBalance(); balance != 0 {
+		t.Errorf("MaterialBalance() expected 0, got: %d", balance)
+	}
+	m, _ := ParseMove("f1e2")
+	g.Play(m)
+	if _, ok := g.CheckedKing(); ok {
+		t.Errorf("CheckedKing() expected no check after Be2")
+	}
+	m, _ = ParseMove("e5g5")
+	g.Play(m)
+	m, _ = ParseMove("e2a6")
+	g.Play(m)
+	m, _ = ParseMove("b7a6")
+	g.Play(m)
+	if captured := g.CapturedPieces(true); len(captured) != 2 || PieceLetter(captured[0]) != "B" || PieceLetter(captured[1]) != "P" {
+		t.Errorf("CapturedPieces(white) expected a bishop and a pawn, got: %v", captured)
+	}
+	if captured := g.CapturedPieces(false); len(captured) != 1 || PieceLetter(captured[0]) != "P" {
+		t.Errorf("CapturedPieces(black) expected a pawn, got: %v", captured)
+	}
+	if balance := g.MaterialBalance(); balance != -3 {
+		t.Errorf("MaterialBalance() expected -3, got: %d", balance)
+	}
+}
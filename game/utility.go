@@ -54,7 +54,7 @@ func isFigureInThreat(field Board, pos Position, situation Situation) bool {
 func analyzeSituation(field Board, playerIsWhite bool, situation Situation) Situation {
 	enemyKingPos := findKing(field, !playerIsWhite)
 	if isFigureInThreat(field, enemyKingPos, situation) {
-		if isCheckmate(field, playerIsWhite, enemyKingPos, situation) {
+		if isCheckmate(field, playerIsWhite, situation) {
 			return Checkmate
 		}
 		return Check
@@ -76,7 +76,7 @@ func findEnemyPositions(field Board, playerIsWhite bool) []Position {
 	return enemyPositions
 }
 
-func isCheckmate(field Board, playerIsWhite bool, enemyKingPos Position, situation Situation) bool {
+func isCheckmate(field Board, playerIsWhite bool, situation Situation) bool {
 	for _, enemyPosition := range findEnemyPositions(field, playerIsWhite) {
 		for fieldPos := range field.Cells {
 			if enemyPosition == fieldPos {
@@ -85,7 +85,7 @@ func isCheckmate(field Board, playerIsWhite bool, enemyKingPos Position, situati
 			canMove, details := field.Cells[enemyPosition].canMove(field, enemyPosition, fieldPos, situation)
 			if canMove {
 				filedAfterMove := field.Cells[enemyPosition].move(field, enemyPosition, fieldPos, details)
-				if !isFigureInThreat(filedAfterMove, enemyKingPos, situation) {
+				if !isFigureInThreat(filedAfterMove, findKing(filedAfterMove, !playerIsWhite), situation) {
 					return false
 				}
 			}
@@ -108,3 +108,14 @@ func isStalemate(field Board, playerIsWhite bool, situation Situation) bool {
 	}
 	return true
 }
+
+// isKingCapture reports whether the move takes the enemy king. A piece
+// attacks the enemy king even if moving would expose its own king.
+func isKingCapture(field Board, to Position) bool {
+	figure := field.Cells[to]
+	if figure == nil {
+		return false
+	}
+	_, ok := figure.Mover.(King)
+	return ok
+}
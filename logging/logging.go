@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// Setup makes slog log records of level and above to w, as "text" or "json".
+// Records logged with the context of a request carry its request id, and
+// its game id once known. The log package writes through it too.
+func Setup(w io.Writer, level, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("log level %q: %w", level, err)
+	}
+	options := &slog.HandlerOptions{Level: l}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, options)
+	case "json":
+		h = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("log format %q is neither text nor json", format)
+	}
+	slog.SetDefault(slog.New(contextHandler{h}))
+	return nil
+}
+
+// request is what a request context knows for its log records. The game id
+// is set by the handler once it knows the game.
+type request struct {
+	id     string
+	gameId atomic.Int64
+}
+
+type requestKey struct{}
+
+// WithRequestId returns a context logging with the request id.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	r := &request{id: id}
+	return context.WithValue(ctx, requestKey{}, r)
+}
+
+// RequestId returns the request id of the context, empty without one.
+func RequestId(ctx context.Context) string {
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		return r.id
+	}
+	return ""
+}
+
+// SetGameId tells the request of the context which game it is about.
+func SetGameId(ctx context.Context, gameId int) {
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		r.gameId.Store(int64(gameId))
+	}
+}
+
+// GameId returns the game id set for the request of the context, zero
+// without one.
+func GameId(ctx context.Context) int {
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		return int(r.gameId.Load())
+	}
+	return 0
+}
+
+// NewRequestId returns a random request id.
+func NewRequestId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// contextHandler adds the request of the context to the records. A record
+// naming its game keeps it, e.g. a rematch created by a request about the
+// previous game.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		record.AddAttrs(slog.String("request_id", r.id))
+		if gameId := r.gameId.Load(); gameId != 0 && !hasAttr(record, "game_id") {
+			record.AddAttrs(slog.Int64("game_id", gameId))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func hasAttr(record slog.Record, key string) bool {
+	found := false
+	record.Attrs(func(a slog.Attr) bool {
+		found = a.Key == key
+		return !found
+	})
+	return found
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	var buf bytes.Buffer
+	if err := Setup(&buf, "warn", "json"); err != nil {
+		t.Fatalf("Setup expected to succeed, got: %v", err)
+	}
+	slog.Info("hidden")
+	slog.Warn("shown", "n", 1)
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected a single JSON record, got: %q", buf.String())
+	}
+	if record["msg"] != "shown" || record["level"] != "WARN" {
+		t.Errorf("expected the warning only, got: %v", record)
+	}
+
+	buf.Reset()
+	if err := Setup(&buf, "debug", "text"); err != nil {
+		t.Fatalf("Setup expected to succeed, got: %v", err)
+	}
+	slog.Debug("shown")
+	if !strings.Contains(buf.String(), "level=DEBUG msg=shown") {
+		t.Errorf("expected a text record, got: %q", buf.String())
+	}
+
+	if err := Setup(&buf, "loud", "text"); err == nil {
+		t.Error("unknown level expected to fail")
+	}
+	if err := Setup(&buf, "info", "xml"); err == nil {
+		t.Error("unknown format expected to fail")
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(contextHandler{slog.NewTextHandler(&buf, nil)})
+	ctx := WithRequestId(context.Background(), "abc")
+	if RequestId(ctx) != "abc" || RequestId(context.Background()) != "" {
+		t.Errorf("expected the request id of the context only, got: %q", RequestId(ctx))
+	}
+
+	logger.InfoContext(ctx, "before")
+	if !strings.Contains(buf.String(), "request_id=abc") || strings.Contains(buf.String(), "game_id") {
+		t.Errorf("expected the request id without a game, got: %q", buf.String())
+	}
+	buf.Reset()
+	SetGameId(ctx, 7)
+	logger.InfoContext(ctx, "after")
+	if !strings.Contains(buf.String(), "request_id=abc game_id=7") || GameId(ctx) != 7 {
+		t.Errorf("expected the game set by the handler, got: %q", buf.String())
+	}
+	buf.Reset()
+	logger.InfoContext(ctx, "other", "game_id", 8)
+	if strings.Count(buf.String(), "game_id") != 1 || !strings.Contains(buf.String(), "game_id=8") {
+		t.Errorf("expected the game of the record to win, got: %q", buf.String())
+	}
+	buf.Reset()
+	logger.Info("outside")
+	if strings.Contains(buf.String(), "request_id") {
+		t.Errorf("expected no request id without a request, got: %q", buf.String())
+	}
+	if NewRequestId() == NewRequestId() {
+		t.Error("expected random request ids")
+	}
+}
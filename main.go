@@ -1,9 +1,12 @@
 package main
 
 import (
+	"lets-go-chess/cecp"
 	"lets-go-chess/cli"
+	"lets-go-chess/logging"
 	"lets-go-chess/server"
-	"log"
+	"lets-go-chess/tablebase"
+	"log/slog"
 	"os"
 	"strings"
 
@@ -12,7 +15,8 @@ import (
 
 func main() {
 	loadConfig()
-	chooseMode(1)
+	setupLogging()
+	chooseMode(viper.GetInt("mode"))
 }
 
 func loadConfig() {
@@ -24,7 +28,17 @@ func loadConfig() {
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	err := viper.ReadInConfig()
 	if err != nil {
-		log.Fatalf("Error reading config file, %s", err)
+		slog.Error("Error reading config file", "error", err)
+		os.Exit(1)
+	}
+}
+
+// setupLogging logs to stderr, stdout is the protocol of the XBoard mode.
+func setupLogging() {
+	err := logging.Setup(os.Stderr, viper.GetString("log.level"), viper.GetString("log.format"))
+	if err != nil {
+		slog.Error("Error setting up logging", "error", err)
+		os.Exit(1)
 	}
 }
 
@@ -34,5 +48,9 @@ func chooseMode(mode int) {
 		cli.StartGame()
 	case 1:
 		server.StartServer()
+	case 2:
+		cecp.StartEngine()
+	case 3:
+		tablebase.GenerateTables()
 	}
 }
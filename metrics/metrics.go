@@ -0,0 +1,338 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of a latency histogram in seconds.
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds the metrics of a process and writes them in the Prometheus
+// text exposition format.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// desc is what every metric has: its name, help, type and label names.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+func (r *Registry) register(d desc, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[d.name] {
+		panic("metric registered twice: " + d.name)
+	}
+	r.names[d.name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// vector keeps one value per combination of label values, in the order the
+// label names were given.
+type vector[T any] struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*T
+	values map[string][]string
+	create func() *T
+}
+
+func newVector[T any](d desc, create func() *T) *vector[T] {
+	return &vector[T]{desc: d, series: make(map[string]*T), values: make(map[string][]string), create: create}
+}
+
+// with returns the series of the label values, creating it at first use.
+// The caller holds v.mu.
+func (v *vector[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metric %v expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = v.create()
+		v.series[key] = s
+		v.values[key] = slices.Clone(values)
+	}
+	return s
+}
+
+// each calls f with the series sorted by their label values. The caller
+// holds v.mu.
+func (v *vector[T]) each(f func(labels string, s *T)) {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		f(formatLabels(v.labels, v.values[key]), v.series[key])
+	}
+}
+
+// Counter is a value which only goes up, e.g. the number of requests.
+type Counter struct {
+	*vector[float64]
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVector(desc{name: name, help: help, kind: "counter", labels: labels}, func() *float64 { return new(float64) })}
+	r.register(c.desc, c)
+	return c
+}
+
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add adds v, which must not be negative.
+func (c *Counter) Add(v float64, labels ...string) {
+	if v < 0 {
+		panic("counter " + c.name + " cannot decrease")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.with(labels) += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w)
+	c.each(func(labels string, v *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labels, formatValue(*v))
+	})
+}
+
+// Gauge is a value which goes up and down, e.g. the connected clients.
+type Gauge struct {
+	*vector[float64]
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newVector(desc{name: name, help: help, kind: "gauge", labels: labels}, func() *float64 { return new(float64) })}
+	r.register(g.desc, g)
+	return g
+}
+
+func (g *Gauge) Set(v float64, labels ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.with(labels) = v
+}
+
+func (g *Gauge) Add(v float64, labels ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.with(labels) += v
+}
+
+func (g *Gauge) Inc(labels ...string) {
+	g.Add(1, labels...)
+}
+
+func (g *Gauge) Dec(labels ...string) {
+	g.Add(-1, labels...)
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.header(w)
+	g.each(func(labels string, v *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labels, formatValue(*v))
+	})
+}
+
+// funcMetric is a counter or gauge without labels read when it is written,
+// for values another package keeps anyway.
+type funcMetric struct {
+	desc
+	value func() float64
+}
+
+// NewCounterFunc registers a counter whose value is read from f.
+func (r *Registry) NewCounterFunc(name, help string, f func() float64) {
+	m := &funcMetric{desc{name: name, help: help, kind: "counter"}, f}
+	r.register(m.desc, m)
+}
+
+// NewGaugeFunc registers a gauge whose value is read from f.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	m := &funcMetric{desc{name: name, help: help, kind: "gauge"}, f}
+	r.register(m.desc, m)
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	m.header(w)
+	fmt.Fprintf(w, "%s %s\n", m.name, formatValue(m.value()))
+}
+
+type histogramSeries struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Histogram counts observations, e.g. latencies, in buckets of their upper
+// bounds.
+type Histogram struct {
+	*vector[histogramSeries]
+	buckets []float64
+}
+
+// NewHistogram registers a histogram with the given ascending bucket upper
+// bounds, DefaultBuckets if there are none.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	if !slices.IsSorted(buckets) {
+		panic("buckets of " + name + " are not sorted")
+	}
+	h := &Histogram{buckets: buckets}
+	h.vector = newVector(desc{name: name, help: help, kind: "histogram", labels: labels}, func() *histogramSeries {
+		return &histogramSeries{counts: make([]uint64, len(buckets))}
+	})
+	r.register(h.desc, h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labels ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.with(labels)
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	h.each(func(labels string, s *histogramSeries) {
+		// buckets are cumulative in the exposition format
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLe(labels, formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLe(labels, "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, s.count)
+	})
+}
+
+// WriteTo writes every metric in the order they were registered.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP serves the metrics to a Prometheus scrape.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func withLe(labels, le string) string {
+	if labels == "" {
+		return `{le="` + le + `"}`
+	}
+	return labels[:len(labels)-1] + `,le="` + le + `"}`
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("requests_total", "Requests by route.", "route", "status")
+	clients := r.NewGauge("clients", "Connected clients.\nNow.")
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("answer", "The answer.", func() float64 { return 42 })
+
+	requests.Inc("/b", "200")
+	requests.Add(2, "/a", "404")
+	requests.Inc(`/"q"`, "200")
+	clients.Inc()
+	clients.Inc()
+	clients.Dec()
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.1, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(3, "/a")
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo expected to succeed, got: %v", err)
+	}
+	expected := `# HELP requests_total Requests by route.
+# TYPE requests_total counter
+requests_total{route="/\"q\"",status="200"} 1
+requests_total{route="/a",status="404"} 2
+requests_total{route="/b",status="200"} 1
+# HELP clients Connected clients.\nNow.
+# TYPE clients gauge
+clients 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 2
+latency_seconds_bucket{route="/a",le="1"} 3
+latency_seconds_bucket{route="/a",le="+Inf"} 4
+latency_seconds_sum{route="/a"} 3.65
+latency_seconds_count{route="/a"} 4
+# HELP answer The answer.
+# TYPE answer gauge
+answer 42
+`
+	if b.String() != expected {
+		t.Errorf("WriteTo expected:\n%v\ngot:\n%v", expected, b.String())
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") || rec.Body.String() != expected {
+		t.Errorf("ServeHTTP expected the text format, got: %v", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("c", "C.", "label")
+	tests := []struct {
+		name string
+		f    func()
+	}{
+		{name: "twice", f: func() { r.NewGauge("c", "C.") }},
+		{name: "decrease", f: func() { c.Add(-1, "x") }},
+		{name: "labels", f: func() { c.Inc() }},
+		{name: "buckets", f: func() { r.NewHistogram("h", "H.", []float64{1, 0.5}) }},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%v: expected a panic", test.name)
+				}
+			}()
+			test.f()
+		}()
+	}
+}
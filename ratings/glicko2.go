@@ -0,0 +1,95 @@
+package ratings
+
+import "math"
+
+const (
+	// glickoScale converts between the Glicko and the Glicko-2 scale
+	glickoScale = 173.7178
+	// tau constrains the change of the volatility over time
+	tau = 0.5
+	// convergence tolerance of the volatility iteration
+	epsilon = 0.000001
+)
+
+// Outcome is one game of a rating period from the player's point of view,
+// Score is 1 for a win, 0.5 for a draw and 0 for a loss.
+type Outcome struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Update returns the player's rating after a rating period, following
+// Glickman's "Example of the Glicko-2 system". A period without games only
+// increases the deviation.
+func Update(player Rating, outcomes []Outcome) Rating {
+	mu := (player.Rating - DefaultRating) / glickoScale
+	phi := player.Deviation / glickoScale
+	sigma := player.Volatility
+	if len(outcomes) == 0 {
+		phi = math.Sqrt(phi*phi + sigma*sigma)
+		return Rating{Rating: player.Rating, Deviation: phi * glickoScale, Volatility: sigma, Games: player.Games}
+	}
+
+	var vInverse, improvement float64
+	for _, o := range outcomes {
+		muJ := (o.Opponent.Rating - DefaultRating) / glickoScale
+		g := gFactor(o.Opponent.Deviation / glickoScale)
+		e := expectedScore(mu, muJ, g)
+		vInverse += g * g * e * (1 - e)
+		improvement += g * (o.Score - e)
+	}
+	v := 1 / vInverse
+	delta := v * improvement
+
+	sigma = newVolatility(phi, sigma, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * improvement
+	return Rating{
+		Rating:     mu*glickoScale + DefaultRating,
+		Deviation:  phi * glickoScale,
+		Volatility: sigma,
+		Games:      player.Games + len(outcomes),
+	}
+}
+
+func gFactor(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func expectedScore(mu, muJ, g float64) float64 {
+	return 1 / (1 + math.Exp(-g*(mu-muJ)))
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm.
+func newVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
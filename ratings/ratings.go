@@ -0,0 +1,118 @@
+package ratings
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRating     = 1500
+	DefaultDeviation  = 350
+	DefaultVolatility = 0.06
+)
+
+type Category string
+
+const (
+	Bullet         Category = "bullet"
+	Blitz          Category = "blitz"
+	Rapid          Category = "rapid"
+	Classical      Category = "classical"
+	Correspondence Category = "correspondence"
+)
+
+// Categories lists the time control categories from fastest to slowest.
+var Categories = []Category{Bullet, Blitz, Rapid, Classical, Correspondence}
+
+var UnknownCategory = errors.New("unknown time control category")
+
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+	Games      int     `json:"games"`
+}
+
+// Entry is a rating after a rated game.
+type Entry struct {
+	Rating
+	GameId int       `json:"gameId"`
+	Time   time.Time `json:"time"`
+}
+
+type key struct {
+	userId   int
+	category Category
+}
+
+var (
+	mu      sync.RWMutex
+	current = make(map[key]Rating)
+	history = make(map[key][]Entry)
+)
+
+// CategoryOf classifies a time control by the expected duration of a game of
+// 40 moves, games without a clock are correspondence games.
+func CategoryOf(initial, increment time.Duration) Category {
+	if initial <= 0 {
+		return Correspondence
+	}
+	switch estimated := initial + 40*increment; {
+	case estimated < 3*time.Minute:
+		return Bullet
+	case estimated < 8*time.Minute:
+		return Blitz
+	case estimated < 25*time.Minute:
+		return Rapid
+	}
+	return Classical
+}
+
+func ParseCategory(s string) (Category, error) {
+	for _, c := range Categories {
+		if string(c) == s {
+			return c, nil
+		}
+	}
+	return "", UnknownCategory
+}
+
+// Get returns the rating of a user, the default rating until the first
+// rated game.
+func Get(userId int, category Category) Rating {
+	mu.RLock()
+	defer mu.RUnlock()
+	return get(userId, category)
+}
+
+func get(userId int, category Category) Rating {
+	if r, ok := current[key{userId, category}]; ok {
+		return r
+	}
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// History returns the ratings of a user after each rated game, oldest first.
+func History(userId int, category Category) []Entry {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Entry(nil), history[key{userId, category}]...)
+}
+
+// RecordGame updates both players after a rated game, each game being a
+// rating period of its own. whiteScore is 1 for a white win, 0.5 for a draw
+// and 0 for a black win.
+func RecordGame(gameId int, category Category, whiteId, blackId int, whiteScore float64) {
+	mu.Lock()
+	defer mu.Unlock()
+	white, black := get(whiteId, category), get(blackId, category)
+	record(whiteId, category, gameId, Update(white, []Outcome{{Opponent: black, Score: whiteScore}}))
+	record(blackId, category, gameId, Update(black, []Outcome{{Opponent: white, Score: 1 - whiteScore}}))
+}
+
+func record(userId int, category Category, gameId int, r Rating) {
+	k := key{userId, category}
+	current[k] = r
+	history[k] = append(history[k], Entry{Rating: r, GameId: gameId, Time: time.Now()})
+}
@@ -0,0 +1,58 @@
+package ratings
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUpdateGlickmanExample(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	updated := Update(player, []Outcome{
+		{Opponent: Rating{Rating: 1400, Deviation: 30}, Score: 1},
+		{Opponent: Rating{Rating: 1550, Deviation: 100}, Score: 0},
+		{Opponent: Rating{Rating: 1700, Deviation: 300}, Score: 0},
+	})
+	if math.Abs(updated.Rating-1464.06) > 0.01 || math.Abs(updated.Deviation-151.52) > 0.01 || math.Abs(updated.Volatility-0.05999) > 0.00001 {
+		t.Errorf("Update() expected 1464.06, 151.52, 0.05999, got: %.2f, %.2f, %.5f", updated.Rating, updated.Deviation, updated.Volatility)
+	}
+	if updated.Games != 3 {
+		t.Errorf("Update() expected 3 games, got: %d", updated.Games)
+	}
+	if idle := Update(player, nil); idle.Rating != player.Rating || idle.Deviation <= player.Deviation {
+		t.Errorf("Update() without games expected a growing deviation, got: %+v", idle)
+	}
+}
+
+func TestCategoryOf(t *testing.T) {
+	tests := []struct {
+		initial, increment time.Duration
+		eCategory          Category
+	}{
+		{initial: 2 * time.Minute, increment: time.Second, eCategory: Bullet},
+		{initial: 5 * time.Minute, increment: 3 * time.Second, eCategory: Blitz},
+		{initial: 15 * time.Minute, increment: 10 * time.Second, eCategory: Rapid},
+		{initial: 30 * time.Minute, eCategory: Classical},
+		{eCategory: Correspondence},
+	}
+	for _, test := range tests {
+		if c := CategoryOf(test.initial, test.increment); c != test.eCategory {
+			t.Errorf("CategoryOf(%v, %v) expected %v, got: %v", test.initial, test.increment, test.eCategory, c)
+		}
+	}
+}
+
+func TestRecordGame(t *testing.T) {
+	RecordGame(1, Blitz, 10, 11, 1)
+	RecordGame(2, Blitz, 10, 11, 0.5)
+	winner, loser := Get(10, Blitz), Get(11, Blitz)
+	if winner.Rating <= DefaultRating || loser.Rating >= DefaultRating || winner.Games != 2 {
+		t.Errorf("RecordGame() expected the winner above and the loser below %d, got: %+v, %+v", DefaultRating, winner, loser)
+	}
+	if Get(10, Bullet).Games != 0 {
+		t.Errorf("RecordGame() expected other categories to be untouched")
+	}
+	if h := History(10, Blitz); len(h) != 2 || h[0].GameId != 1 || h[1].Rating != winner {
+		t.Errorf("History() expected both games, got: %+v", h)
+	}
+}
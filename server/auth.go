@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"lets-go-chess/accounts"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const sessionCookie = "session"
+
+var LoginRequired = errors.New("login required")
+
+type userContextKey struct{}
+
+type credentialsRequest struct {
+	Name     string `json:"name" schema:"required"`
+	Password string `json:"password" schema:"required"`
+}
+
+type userResponse struct {
+	UserId int    `json:"userId"`
+	Name   string `json:"name"`
+}
+
+// sessionResponse carries the session token for clients which send it as a
+// bearer token instead of the cookie.
+type sessionResponse struct {
+	userResponse
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// authMiddleware resolves the session of a bearer token or the session
+// cookie and puts its user in the request context. Requests without a
+// session pass anonymously, see userFrom. An invalid or expired session is
+// refused when strict, otherwise the request passes anonymously and the
+// stale cookie is cleared.
+func authMiddleware(strict bool, handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := sessionToken(r)
+		if token != "" {
+			u, err := accounts.UserBySession(token)
+			switch {
+			case err == nil:
+				r = r.WithContext(context.WithValue(r.Context(), userContextKey{}, u))
+			case strict:
+				writeError(w, err, nil)
+				return
+			default:
+				if _, cookieErr := r.Cookie(sessionCookie); cookieErr == nil {
+					http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+				}
+			}
+		}
+		handler(w, r)
+	}
+}
+
+// requireUser rejects anonymous requests, it goes inside authMiddleware.
+func requireUser(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if userFrom(r) == nil {
+			writeError(w, LoginRequired, nil)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// userFrom returns the logged in user, nil for anonymous requests.
+func userFrom(r *http.Request) *accounts.User {
+	u, _ := r.Context().Value(userContextKey{}).(*accounts.User)
+	return u
+}
+
+func sessionToken(r *http.Request) string {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return bearer
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func register(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "Error unmarshalling request", "error", err)
+		writeError(w, InvalidRequest, err.Error())
+		return
+	}
+	u, err := accounts.Register(req.Name, req.Password)
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, &userResponse{UserId: u.Id, Name: u.Name})
+}
+
+func login(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "Error unmarshalling request", "error", err)
+		writeError(w, InvalidRequest, err.Error())
+		return
+	}
+	u, token, expires, err := accounts.Login(req.Name, req.Password)
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   viper.GetBool("accounts.secureCookie"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	writeJSON(w, &sessionResponse{userResponse: userResponse{UserId: u.Id, Name: u.Name}, Token: token, Expires: expires})
+}
+
+func logout(w http.ResponseWriter, r *http.Request) {
+	accounts.Logout(sessionToken(r))
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func me(w http.ResponseWriter, r *http.Request) {
+	u := userFrom(r)
+	writeJSON(w, &userResponse{UserId: u.Id, Name: u.Name})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"lets-go-chess/game"
+	"sync"
+)
+
+const subscriberBuffer = 64
+
+// gameEvent is a change of a game pushed to live clients. Id numbers the
+// logged events of a game from 1, clock ticks are not logged and have no id.
+// Ply is the number of moves played when the event happened.
+type gameEvent struct {
+	Id        int            `json:"id,omitempty"`
+	Type      string         `json:"type"`
+	Ply       int            `json:"ply"`
+	Move      string         `json:"move,omitempty"`
+	San       string         `json:"san,omitempty"`
+	Situation game.Situation `json:"situation"`
+	IsWhite   bool           `json:"isWhite"`
+	Board     [][]string     `json:"board,omitempty"`
+	Result    string         `json:"result,omitempty"`
+	By        string         `json:"by,omitempty"`
+	Clock     *clockResponse `json:"clock,omitempty"`
+	Error     *errorResponse `json:"error,omitempty"`
+	Chat      *chatMessage   `json:"chat,omitempty"`
+	RematchId int            `json:"rematchId,omitempty"`
+	Seat      *seatResponse  `json:"seat,omitempty"`
+	// audience restricts the event to the player of a colour or to the
+	// spectators, empty is everyone
+	audience string
+}
+
+const (
+	moveEvent             = "move"
+	resultEvent           = "result"
+	clockEvent            = "clock"
+	drawOfferEvent        = "drawOffer"
+	drawDeclinedEvent     = "drawDeclined"
+	joinEvent             = "join"
+	takebackOfferEvent    = "takebackOffer"
+	takebackDeclinedEvent = "takebackDeclined"
+	takebackEvent         = "takeback"
+	rematchOfferEvent     = "rematchOffer"
+	rematchDeclinedEvent  = "rematchDeclined"
+	rematchEvent          = "rematch"
+	chatEvent             = "chat"
+	errorEvent            = "error"
+)
+
+// broadcaster fans the events of one game out to its subscribers and keeps
+// them, so that a reconnecting client can resume where it left off. Each
+// subscriber maps to the filter of the events it may see.
+type broadcaster struct {
+	mu          sync.Mutex
+	events      []gameEvent
+	subscribers map[chan gameEvent]func(gameEvent) bool
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{subscribers: make(map[chan gameEvent]func(gameEvent) bool)}
+}
+
+// publish sends the event to every subscriber that may see it. A subscriber
+// too slow to keep up is dropped by closing its channel, it may resume from
+// the log.
+func (b *broadcaster) publish(e gameEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e.Type != clockEvent {
+		e.Id = len(b.events) + 1
+		b.events = append(b.events, e)
+	}
+	for ch, visible := range b.subscribers {
+		if !visible(e) {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns the logged events the filter accepts together with a
+// channel receiving every later event. Events visible rejects are left out
+// of both.
+func (b *broadcaster) subscribe(filter, visible func(gameEvent) bool) ([]gameEvent, chan gameEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var backlog []gameEvent
+	for _, e := range b.events {
+		if filter(e) && visible(e) {
+			backlog = append(backlog, e)
+		}
+	}
+	ch := make(chan gameEvent, subscriberBuffer)
+	b.subscribers[ch] = visible
+	return backlog, ch
+}
+
+func (b *broadcaster) unsubscribe(ch chan gameEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
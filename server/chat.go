@@ -0,0 +1,263 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"lets-go-chess/accounts"
+	"lets-go-chess/storage"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	playersRoom    = "players"
+	spectatorsRoom = "spectators"
+	maxChatLength  = 140
+	// a sender may post chatBurst messages per chatWindow
+	chatBurst  = 5
+	chatWindow = 10 * time.Second
+)
+
+var (
+	InvalidChatMessage = errors.New("chat message must be 1-140 characters")
+	ChatRateLimited    = errors.New("too many chat messages")
+)
+
+// chatMessage is a chat line in the players' or in the spectators' room. The
+// players only see their room and the spectators only theirs.
+type chatMessage struct {
+	Room string    `json:"room"`
+	From string    `json:"from"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+	// userId is the sender's account, zero for anonymous players
+	userId int
+	color  string
+}
+
+type chatRequest struct {
+	Text string `json:"text" schema:"required"`
+}
+
+// chatViewer is who reads a chat: the player of a seat or a spectator,
+// either of them logged in or not.
+type chatViewer struct {
+	seated *bool
+	userId int
+}
+
+func viewer(seated *bool, r *http.Request) chatViewer {
+	v := chatViewer{seated: seated}
+	if u := userFrom(r); u != nil {
+		v.userId = u.Id
+	}
+	return v
+}
+
+// sees tells whether the viewer gets the message: it has to be in the
+// viewer's room, not from an opponent the player muted and not from a user
+// the viewer blocked. The caller holds lg.mu.
+func (lg *liveGame) sees(v chatViewer, c *chatMessage) bool {
+	if (c.Room == playersRoom) != (v.seated != nil) {
+		return false
+	}
+	if v.seated != nil && c.color != colorName(*v.seated) && lg.seat(*v.seated).muted {
+		return false
+	}
+	return v.userId == 0 || c.userId == 0 || !accounts.IsBlocked(v.userId, c.userId)
+}
+
+// visibleTo filters the events of a subscriber down to the ones meant for
+// it and the chat it may see. Events are published holding lg.mu, subscribe
+// has to hold it too.
+func (lg *liveGame) visibleTo(v chatViewer) func(gameEvent) bool {
+	return func(e gameEvent) bool {
+		switch {
+		case e.audience == spectatorsRoom:
+			return v.seated == nil
+		case e.audience != "":
+			return v.seated != nil && colorName(*v.seated) == e.audience
+		}
+		return e.Type != chatEvent || lg.sees(v, e.Chat)
+	}
+}
+
+// chat posts a message to the room of the sender, spectators need an
+// account to chat. The caller holds lg.mu.
+func (lg *liveGame) chat(ctx context.Context, seated *bool, u *accounts.User, text string) error {
+	text = strings.TrimFunc(text, unicode.IsSpace)
+	if text == "" || utf8.RuneCountInString(text) > maxChatLength || strings.ContainsFunc(text, unicode.IsControl) {
+		return InvalidChatMessage
+	}
+	c := &chatMessage{Room: spectatorsRoom, Text: censor(text), Time: time.Now()}
+	if seated != nil {
+		c.Room = playersRoom
+		c.color = colorName(*seated)
+		c.From = c.color
+	} else if u == nil {
+		return LoginRequired
+	}
+	sender := c.color
+	if u != nil {
+		c.userId = u.Id
+		c.From = u.Name
+		sender = u.Name
+	}
+	if !lg.allowChat(sender, c.Time) {
+		return ChatRateLimited
+	}
+	storage.AppendChat(ctx, lg.id, storage.ChatMessage{Room: c.Room, UserId: c.userId, Color: c.color, From: c.From, Text: c.Text, Time: c.Time})
+	lg.publish(gameEvent{Type: chatEvent, By: c.color, Chat: c})
+	return nil
+}
+
+// allowChat records a message of the sender unless it already sent chatBurst
+// messages within chatWindow. The caller holds lg.mu.
+func (lg *liveGame) allowChat(sender string, now time.Time) bool {
+	if lg.chatSent == nil {
+		lg.chatSent = make(map[string][]time.Time)
+	}
+	sent := lg.chatSent[sender]
+	for len(sent) > 0 && now.Sub(sent[0]) >= chatWindow {
+		sent = sent[1:]
+	}
+	if len(sent) >= chatBurst {
+		lg.chatSent[sender] = sent
+		return false
+	}
+	lg.chatSent[sender] = append(sent, now)
+	return true
+}
+
+// bannedWords caches the expression of chat.bannedWords, it is compiled
+// again only when the setting changes.
+var bannedWords struct {
+	mu     sync.Mutex
+	words  []string
+	banned *regexp.Regexp
+}
+
+// censor replaces the words of chat.bannedWords by asterisks, ignoring case.
+func censor(text string) string {
+	banned := bannedExpression(viper.GetStringSlice("chat.bannedWords"))
+	if banned == nil {
+		return text
+	}
+	return banned.ReplaceAllStringFunc(text, func(w string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(w))
+	})
+}
+
+// bannedExpression returns the expression matching the words, nil for none.
+func bannedExpression(words []string) *regexp.Regexp {
+	bannedWords.mu.Lock()
+	defer bannedWords.mu.Unlock()
+	if slices.Equal(words, bannedWords.words) {
+		return bannedWords.banned
+	}
+	bannedWords.words, bannedWords.banned = words, nil
+	if len(words) > 0 {
+		quoted := make([]string, 0, len(words))
+		for _, w := range words {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+		bannedWords.banned = regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	}
+	return bannedWords.banned
+}
+
+// mute hides the chat of the opponent from a player, or shows it again.
+// The caller holds lg.mu.
+func (lg *liveGame) mute(isWhite, muted bool) {
+	lg.seat(isWhite).muted = muted
+}
+
+// chatHistory returns the stored chat of a game the caller may see.
+func chatHistory(w http.ResponseWriter, r *http.Request) {
+	lg, seated, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	v := viewer(seated, r)
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	resp := make([]*chatMessage, 0)
+	for _, m := range storage.GetChat(lg.id) {
+		c := &chatMessage{Room: m.Room, From: m.From, Text: m.Text, Time: m.Time, userId: m.UserId, color: m.Color}
+		if lg.sees(v, c) {
+			resp = append(resp, c)
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// postChat is the chat of clients on the event stream, WebSocket clients
+// chat over the socket.
+func postChat(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "Error unmarshalling request", "error", err)
+		writeError(w, InvalidRequest, err.Error())
+		return
+	}
+	lg, seated, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	lg.mu.Lock()
+	err := lg.chat(r.Context(), seated, userFrom(r), req.Text)
+	lg.mu.Unlock()
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// muteChat mutes the opponent's chat for the player, DELETE unmutes it.
+func muteChat(w http.ResponseWriter, r *http.Request) {
+	lg, seated, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	if seated == nil {
+		writeError(w, SeatTokenRequired, nil)
+		return
+	}
+	lg.mu.Lock()
+	lg.mute(*seated, r.Method != http.MethodDelete)
+	lg.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// blockUser hides the chat of the user in the path from the logged in user in
+// every game, DELETE unblocks them.
+func blockUser(w http.ResponseWriter, r *http.Request) {
+	blocked, ok := userFromPath(w, r)
+	if !ok {
+		return
+	}
+	u := userFrom(r)
+	if blocked.Id == u.Id {
+		writeError(w, InvalidRequest, "users cannot block themselves")
+		return
+	}
+	if r.Method == http.MethodDelete {
+		accounts.Unblock(u.Id, blocked.Id)
+	} else {
+		accounts.Block(u.Id, blocked.Id)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"lets-go-chess/ratings"
+	"time"
+)
+
+// clock is a chess clock with Fischer increment. It starts running for black
+// after white's first move.
+type clock struct {
+	white     time.Duration
+	black     time.Duration
+	increment time.Duration
+	// turnStarted is when the side to move started thinking, zero until the
+	// first move
+	turnStarted time.Time
+	whiteToMove bool
+}
+
+// timeControlRequest gives the time of each side and the increment per move
+// in seconds.
+type timeControlRequest struct {
+	Initial   int `json:"initial" schema:"required,min=1"`
+	Increment int `json:"increment" schema:"min=0"`
+}
+
+type clockResponse struct {
+	WhiteMs int64 `json:"whiteMs"`
+	BlackMs int64 `json:"blackMs"`
+	Running bool  `json:"running"`
+}
+
+// category is the rating category of the time control, games without a
+// clock are correspondence games.
+func (tc *timeControlRequest) category() ratings.Category {
+	if tc == nil {
+		return ratings.Correspondence
+	}
+	return ratings.CategoryOf(time.Duration(tc.Initial)*time.Second, time.Duration(tc.Increment)*time.Second)
+}
+
+func newClock(initial, increment time.Duration) *clock {
+	return &clock{white: initial, black: initial, increment: increment, whiteToMove: true}
+}
+
+// newClockFor returns the clock of the time control, nil when there is none.
+func newClockFor(tc *timeControlRequest) (*clock, error) {
+	if tc == nil {
+		return nil, nil
+	}
+	if tc.Initial <= 0 || tc.Increment < 0 {
+		return nil, InvalidRequest
+	}
+	return newClock(time.Duration(tc.Initial)*time.Second, time.Duration(tc.Increment)*time.Second), nil
+}
+
+// remaining returns the time left of both sides at now.
+func (c *clock) remaining(now time.Time) (white, black time.Duration) {
+	white, black = c.white, c.black
+	if !c.turnStarted.IsZero() {
+		elapsed := now.Sub(c.turnStarted)
+		if c.whiteToMove {
+			white -= elapsed
+		} else {
+			black -= elapsed
+		}
+	}
+	return max(white, 0), max(black, 0)
+}
+
+// flagged reports whether the side to move has run out of time.
+func (c *clock) flagged(now time.Time) bool {
+	white, black := c.remaining(now)
+	if c.whiteToMove {
+		return white == 0
+	}
+	return black == 0
+}
+
+// press ends the turn of the side to move.
+func (c *clock) press(now time.Time) {
+	if !c.turnStarted.IsZero() {
+		c.white, c.black = c.remaining(now)
+		if c.whiteToMove {
+			c.white += c.increment
+		} else {
+			c.black += c.increment
+		}
+	}
+	c.whiteToMove = !c.whiteToMove
+	c.turnStarted = now
+}
+
+func (c *clock) response(now time.Time) *clockResponse {
+	white, black := c.remaining(now)
+	return &clockResponse{WhiteMs: white.Milliseconds(), BlackMs: black.Milliseconds(), Running: !c.turnStarted.IsZero()}
+}
+
+// takeBack hands the turn back after plies moves were taken back, spent time
+// and increments stay as they are. Back at the start position the clock
+// waits for white's first move again.
+func (c *clock) takeBack(now time.Time, plies int, atStart bool) {
+	c.white, c.black = c.remaining(now)
+	if plies%2 == 1 {
+		c.whiteToMove = !c.whiteToMove
+	}
+	c.turnStarted = now
+	if atStart {
+		c.turnStarted = time.Time{}
+	}
+}
+
+// stop freezes the clock when the game is over.
+func (c *clock) stop(now time.Time) {
+	c.white, c.black = c.remaining(now)
+	c.turnStarted = time.Time{}
+}
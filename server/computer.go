@@ -0,0 +1,39 @@
+package server
+
+import (
+	"errors"
+	"lets-go-chess/engine"
+
+	"github.com/spf13/viper"
+)
+
+var InvalidOpponent = errors.New("invalid opponent settings")
+
+// computerOpponent is the engine side of a game against the computer.
+type computerOpponent struct {
+	isWhite bool
+	skill   int
+}
+
+// newComputerOpponent reads the opponent settings of a startGame request.
+// Elo is used when no level is given, neither means full strength.
+func newComputerOpponent(req startGameRequest, humanWhite bool) (*computerOpponent, error) {
+	c := &computerOpponent{isWhite: !humanWhite, skill: req.Level}
+	switch {
+	case req.Level != 0 && (req.Level < engine.MinSkill || req.Level > engine.MaxSkill):
+		return nil, InvalidOpponent
+	case req.Level == 0 && req.Elo != 0:
+		c.skill = engine.SkillFromElo(req.Elo)
+	case req.Level == 0:
+		c.skill = engine.MaxSkill
+	}
+	return c, nil
+}
+
+func (c *computerOpponent) limits() engine.Limits {
+	return engine.Limits{
+		MoveTime: viper.GetDuration("engine.moveTime"),
+		Threads:  viper.GetInt("engine.threads"),
+		Skill:    c.skill,
+	}
+}
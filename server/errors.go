@@ -0,0 +1,120 @@
+package server
+
+import (
+	"errors"
+	"lets-go-chess/accounts"
+	"lets-go-chess/game"
+	"lets-go-chess/ratings"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+var (
+	InvalidRequest = errors.New("invalid request")
+	UnknownGame    = errors.New("unknown game")
+	NotYourTurn    = errors.New("not your turn")
+	NotInTablebase = errors.New("position not in tablebase")
+	InternalError  = errors.New("internal error")
+)
+
+// errorResponse is the body of every failed request.
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+type apiError struct {
+	status int
+	code   string
+}
+
+var apiErrors = map[error]apiError{
+	InvalidRequest:              {status: http.StatusBadRequest, code: "invalid_request"},
+	InvalidOpponent:             {status: http.StatusBadRequest, code: "invalid_opponent"},
+	LoginRequired:               {status: http.StatusUnauthorized, code: "login_required"},
+	accounts.InvalidSession:     {status: http.StatusUnauthorized, code: "invalid_session"},
+	accounts.InvalidCredentials: {status: http.StatusUnauthorized, code: "invalid_credentials"},
+	accounts.InvalidName:        {status: http.StatusBadRequest, code: "invalid_name"},
+	accounts.InvalidPassword:    {status: http.StatusBadRequest, code: "invalid_password"},
+	accounts.NameTaken:          {status: http.StatusConflict, code: "name_taken"},
+	SeatTokenRequired:           {status: http.StatusUnauthorized, code: "seat_token_required"},
+	InvalidSeatToken:            {status: http.StatusForbidden, code: "invalid_seat_token"},
+	InvalidInvite:               {status: http.StatusForbidden, code: "invalid_invite"},
+	InvalidChatMessage:          {status: http.StatusBadRequest, code: "invalid_chat_message"},
+	ChatRateLimited:             {status: http.StatusTooManyRequests, code: "chat_rate_limited"},
+	PrivateGame:                 {status: http.StatusForbidden, code: "private_game"},
+	UnknownGame:                 {status: http.StatusNotFound, code: "unknown_game"},
+	UnknownUser:                 {status: http.StatusNotFound, code: "unknown_user"},
+	ratings.UnknownCategory:     {status: http.StatusNotFound, code: "unknown_category"},
+	UnknownSeek:                 {status: http.StatusNotFound, code: "unknown_seek"},
+	InvalidSeekToken:            {status: http.StatusForbidden, code: "invalid_seek_token"},
+	SeekMatched:                 {status: http.StatusConflict, code: "seek_matched"},
+	NotInTablebase:              {status: http.StatusNotFound, code: "not_in_tablebase"},
+	NotYourTurn:                 {status: http.StatusConflict, code: "not_your_turn"},
+	NoTakebackOffer:             {status: http.StatusConflict, code: "no_takeback_offer"},
+	game.NothingToUndo:          {status: http.StatusConflict, code: "nothing_to_undo"},
+	GameNotOver:                 {status: http.StatusConflict, code: "game_not_over"},
+	NoRematchOffer:              {status: http.StatusConflict, code: "no_rematch_offer"},
+	RematchStarted:              {status: http.StatusConflict, code: "rematch_started"},
+	NoDrawOffer:                 {status: http.StatusConflict, code: "no_draw_offer"},
+	game.GameOver:               {status: http.StatusConflict, code: "game_over"},
+	game.InvalidFrom:            {status: http.StatusUnprocessableEntity, code: "invalid_from"},
+	game.ToOutOfBounds:          {status: http.StatusUnprocessableEntity, code: "to_out_of_bounds"},
+	game.MoveRulesViolation:     {status: http.StatusUnprocessableEntity, code: "move_rules_violation"},
+	game.InvalidNotation:        {status: http.StatusBadRequest, code: "invalid_notation"},
+	game.AmbiguousMove:          {status: http.StatusUnprocessableEntity, code: "ambiguous_move"},
+	game.WrongColor:             {status: http.StatusUnprocessableEntity, code: "wrong_color"},
+	InternalError:               {status: http.StatusInternalServerError, code: "internal_error"},
+	ShuttingDown:                {status: http.StatusServiceUnavailable, code: "shutting_down"},
+	TooManyRequests:             {status: http.StatusTooManyRequests, code: "rate_limited"},
+	TooManyOpenGames:            {status: http.StatusTooManyRequests, code: "too_many_open_games"},
+	BodyTooLarge:                {status: http.StatusRequestEntityTooLarge, code: "body_too_large"},
+}
+
+// writeError writes the error envelope with the status mapped from err.
+// The error is kept for the request log.
+func writeError(w http.ResponseWriter, err error, details any) {
+	noteError(w, err)
+	status, resp := toErrorResponse(err, details)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeJSON(w, resp)
+}
+
+// toErrorResponse maps err to its status and envelope. Errors without a
+// mapping are reported as internal errors, the request log keeps them.
+func toErrorResponse(err error, details any) (int, *errorResponse) {
+	apiErr, ok := apiErrors[err]
+	if !ok {
+		for known, e := range apiErrors {
+			if errors.Is(err, known) {
+				apiErr, ok = e, true
+				break
+			}
+		}
+	}
+	if !ok {
+		err = InternalError
+		apiErr = apiErrors[InternalError]
+	}
+	return apiErr.status, &errorResponse{Code: apiErr.code, Message: err.Error(), Details: details}
+}
+
+// recoverMiddleware turns a panicking handler into an internal error
+// response instead of a dropped connection.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				slog.ErrorContext(r.Context(), "Error panic serving", "method", r.Method, "path", r.URL.Path, "panic", rec, "stack", string(debug.Stack()))
+				writeError(w, InternalError, nil)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
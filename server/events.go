@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// gameEvents streams the events of a game as Server-Sent Events, for clients
+// which cannot use the WebSocket. The event id is the gameEvent id, so a
+// client resuming with Last-Event-ID, or lastEventId for the first request,
+// gets the events it missed replayed first.
+func gameEvents(w http.ResponseWriter, r *http.Request) {
+	lg, seated, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	lastId := 0
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = r.URL.Query().Get("lastEventId")
+	}
+	if lastEventId != "" {
+		var err error
+		if lastId, err = strconv.Atoi(lastEventId); err != nil || lastId < 0 {
+			writeError(w, InvalidRequest, "Last-Event-ID must be an event id")
+			return
+		}
+	}
+	l := serverLifecycle()
+	if !l.openStream() {
+		writeError(w, ShuttingDown, nil)
+		return
+	}
+	defer l.closeStream()
+	liveClients.Inc("sse")
+	defer liveClients.Dec("sse")
+	rc := http.NewResponseController(w)
+	// the stream outlives the server write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		slog.ErrorContext(r.Context(), "Error clearing write deadline", "error", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if seated == nil {
+		defer lg.watch()()
+	}
+	lg.mu.Lock()
+	backlog, events := lg.events.subscribe(func(e gameEvent) bool {
+		return e.Id > lastId
+	}, lg.visibleTo(viewer(seated, r)))
+	lg.mu.Unlock()
+	defer lg.events.unsubscribe(events)
+	for _, e := range backlog {
+		if writeEvent(w, e) != nil {
+			return
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				// too slow to keep up, the client reconnects and resumes
+				return
+			}
+			if writeEvent(w, e) != nil {
+				return
+			}
+		case <-ticker.C:
+			// a comment keeps proxies from closing an idle stream
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-l.stopping:
+			// the client reconnects to another instance and resumes
+			return
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w io.Writer, e gameEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("Error marshalling event", "type", e.Type, "error", err)
+		return err
+	}
+	if e.Id != 0 {
+		if _, err = fmt.Fprintf(w, "id: %d\n", e.Id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return err
+}
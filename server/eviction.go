@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"lets-go-chess/game"
+	"lets-go-chess/storage"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// gameSweep is how often the games to drop are looked for
+	gameSweep              = time.Minute
+	defaultFinishedGameTtl = 10 * time.Minute
+)
+
+// runEviction evicts the games to drop on every sweep.
+func runEviction() {
+	ticker := time.NewTicker(gameSweep)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		evictGames(now)
+	}
+}
+
+// evictGames drops the games over for limits.finishedGameTtl, kept that long
+// for the rematch and the last look of the players, and the games with a
+// clock nobody started within limits.openGameIdle, which are given up on as
+// there is no aborting a game. They leave the live games and no longer count
+// for the clients which started them, the stored game and its chat stay.
+// Games without a clock may wait for a move for days, they are only no
+// longer counted once idle. Games with a running clock end by the clock. The
+// games are locked one by one, as elsewhere a game is locked before
+// liveGamesMu.
+func evictGames(now time.Time) {
+	finished := finishedGameTtl()
+	idle := viper.GetDuration("limits.openGameIdle")
+	liveGamesMu.Lock()
+	games := make([]*liveGame, 0, len(liveGames))
+	for _, lg := range liveGames {
+		games = append(games, lg)
+	}
+	liveGamesMu.Unlock()
+	for _, lg := range games {
+		lg.mu.Lock()
+		inactive := now.Sub(lg.lastActive)
+		abandoned := lg.g.Result == game.Ongoing && idle > 0 && inactive > idle
+		switch {
+		case lg.g.Result != game.Ongoing && inactive > finished,
+			abandoned && lg.clock != nil && lg.clock.turnStarted.IsZero():
+			lg.evict()
+		case abandoned && lg.clock == nil:
+			lg.released.Store(true)
+		}
+		lg.mu.Unlock()
+	}
+	openGames.sweep()
+}
+
+// evict drops the live state of the game, the stored game and its chat stay
+// for the rating history and getLiveGame serves them again without seats.
+// The caller holds lg.mu.
+func (lg *liveGame) evict() {
+	lg.evicted.Store(true)
+	lg.released.Store(true)
+	liveGamesMu.Lock()
+	delete(liveGames, lg.id)
+	liveGamesMu.Unlock()
+	storage.DeleteState(context.Background(), lg.id)
+	slog.Debug("Game evicted", "game_id", lg.id, "result", resultName(lg.g.Result))
+}
+
+func finishedGameTtl() time.Duration {
+	if ttl := viper.GetDuration("limits.finishedGameTtl"); ttl > 0 {
+		return ttl
+	}
+	return defaultFinishedGameTtl
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	TooManyRequests  = errors.New("too many requests")
+	TooManyOpenGames = errors.New("too many open games")
+	BodyTooLarge     = errors.New("request body too large")
+)
+
+// bucketSweep is how often the limiters forget the clients whose bucket
+// filled up again.
+const bucketSweep = time.Minute
+
+// routeLimits are the limits of a route: limits.rate, limits.burst and
+// limits.maxBody, overridden by those of limits.routes.<operation>.
+type routeLimits struct {
+	rate    float64
+	burst   float64
+	maxBody int64
+}
+
+func (rt route) limits() routeLimits {
+	l := routeLimits{
+		rate:    viper.GetFloat64("limits.rate"),
+		burst:   viper.GetFloat64("limits.burst"),
+		maxBody: viper.GetInt64("limits.maxBody"),
+	}
+	override := "limits.routes." + rt.operation + "."
+	if viper.IsSet(override + "rate") {
+		l.rate = viper.GetFloat64(override + "rate")
+	}
+	if viper.IsSet(override + "burst") {
+		l.burst = viper.GetFloat64(override + "burst")
+	}
+	if viper.IsSet(override + "maxBody") {
+		l.maxBody = viper.GetInt64(override + "maxBody")
+	} else if rt.maxBody > 0 {
+		l.maxBody = rt.maxBody
+	}
+	if l.maxBody <= 0 {
+		l.maxBody = maxRequestBody
+	}
+	return l
+}
+
+// limiter is a token bucket per client: a request takes a token, tokens come
+// back at rate per second up to burst.
+type limiter struct {
+	rate    float64
+	burst   float64
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newLimiter returns nil for a rate of zero, which is no limit.
+func newLimiter(rate, burst float64) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{rate: rate, burst: max(burst, 1), buckets: make(map[string]*bucket)}
+}
+
+// take takes a token of the client, returning how long it has to wait for
+// one when there is none left.
+func (l *limiter) take(client string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) > bucketSweep {
+		for c, b := range l.buckets {
+			if l.refill(b, now) >= l.burst {
+				delete(l.buckets, c)
+			}
+		}
+		l.swept = now
+	}
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[client] = b
+	}
+	b.tokens, b.updated = l.refill(b, now), now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+func (l *limiter) refill(b *bucket, now time.Time) float64 {
+	return min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+}
+
+// limitRate answers 429 to a client out of tokens, Retry-After tells when it
+// has one again. The address and the logged in user of a request each have
+// a bucket, so that neither switching addresses nor sharing one helps.
+func limitRate(l *limiter, handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	if l == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		wait := l.take("ip:"+clientIP(r), now)
+		if u := userFrom(r); u != nil {
+			wait = max(wait, l.take("user:"+strconv.Itoa(u.Id), now))
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, TooManyRequests, nil)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// clientIP is the address of the client. Behind a proxy, limits.trustProxy
+// takes the last X-Forwarded-For entry, the one the proxy appended.
+func clientIP(r *http.Request) string {
+	if viper.GetBool("limits.trustProxy") {
+		forwarded := r.Header.Values("X-Forwarded-For")
+		if len(forwarded) > 0 {
+			entries := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientKey identifies who created a game, the user if logged in.
+func clientKey(r *http.Request) string {
+	if u := userFrom(r); u != nil {
+		return "user:" + strconv.Itoa(u.Id)
+	}
+	return "ip:" + clientIP(r)
+}
+
+// gameQuota keeps the games each client started to cap how many it has. A
+// game counts until it is released, see evictGames: one which is over still
+// counts for a while, so that ending games right away does not get around
+// the cap. mu is taken before the lock of the game being created, it does
+// not lock the counted games and may be taken holding one of them.
+type gameQuota struct {
+	mu    sync.Mutex
+	games map[string][]*liveGame
+}
+
+var openGames = &gameQuota{games: make(map[string][]*liveGame)}
+
+// start creates a game for the clients, both seekers of a lobby match,
+// unless one of them has limits.openGames games already.
+func (q *gameQuota) start(clients []string, create func() *liveGame) (*liveGame, error) {
+	clients = slices.Compact(slices.Sorted(slices.Values(clients)))
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, client := range clients {
+		if q.full(client) {
+			return nil, TooManyOpenGames
+		}
+	}
+	lg := create()
+	lg.mu.Lock()
+	lg.creators = clients
+	lg.mu.Unlock()
+	for _, client := range clients {
+		q.games[client] = append(q.games[client], lg)
+	}
+	return lg, nil
+}
+
+// check refuses a client with limits.openGames games, before it waits in the
+// lobby for one more.
+func (q *gameQuota) check(client string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.full(client) {
+		return TooManyOpenGames
+	}
+	return nil
+}
+
+// add counts a restored game for the clients which created it.
+func (q *gameQuota) add(clients []string, lg *liveGame) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, client := range clients {
+		q.games[client] = append(q.games[client], lg)
+	}
+}
+
+// sweep forgets the released games and the clients left without a game.
+func (q *gameQuota) sweep() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for client := range q.games {
+		q.prune(client)
+	}
+}
+
+// full tells whether the client has limits.openGames games. The caller
+// holds q.mu.
+func (q *gameQuota) full(client string) bool {
+	limit := viper.GetInt("limits.openGames")
+	return limit > 0 && q.prune(client) >= limit
+}
+
+// prune drops the released games of the client and returns how many are
+// left. The caller holds q.mu.
+func (q *gameQuota) prune(client string) int {
+	games := slices.DeleteFunc(q.games[client], func(lg *liveGame) bool {
+		return lg.released.Load()
+	})
+	if len(games) == 0 {
+		delete(q.games, client)
+	} else {
+		q.games[client] = games
+	}
+	return len(games)
+}
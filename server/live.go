@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"lets-go-chess/engine"
+	"lets-go-chess/game"
+	"lets-go-chess/ratings"
+	"lets-go-chess/storage"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var NoDrawOffer = errors.New("no draw offer to answer")
+
+const clockTick = time.Second
+
+// liveGame is the state the server keeps next to a stored game. mu guards the
+// game and everything else in here, events are published while holding it so
+// that they are logged in the order they happened.
+type liveGame struct {
+	mu          sync.Mutex
+	id          int
+	g           *game.Game
+	computer    *computerOpponent
+	white       *seat
+	black       *seat
+	clock       *clock
+	timeControl *timeControlRequest
+	rated       bool
+	category    ratings.Category
+	private     bool
+	spectators  int
+	chatSent    map[string][]time.Time
+	drawOfferBy string
+	takebackBy  string
+	rematchBy   string
+	rematchOf   int
+	rematchId   int
+	events      *broadcaster
+	// generation counts the changes of the position, moves and takebacks,
+	// so that a computer reply thought of before one is not played
+	generation int
+	// creators are the client keys of who started the game, lastActive
+	// when it was started, last moved in or ended
+	creators   []string
+	lastActive time.Time
+	// evicted is set once the game is dropped and released once it no
+	// longer counts for its creators, see evictGames
+	evicted  atomic.Bool
+	released atomic.Bool
+}
+
+var (
+	liveGamesMu sync.Mutex
+	liveGames   = make(map[int]*liveGame)
+)
+
+// newLiveGame stores a new game and starts its clock, if it has one. The
+// creator holds the seat of creatorWhite, the other seat waits for the
+// invited opponent unless the computer plays it.
+func newLiveGame(ctx context.Context, g *game.Game, creatorWhite bool, computer *computerOpponent, c *clock) *liveGame {
+	lg := &liveGame{g: g, computer: computer, clock: c, events: newBroadcaster(), lastActive: time.Now()}
+	creator := newSeat()
+	creator.invite = ""
+	var opponent *seat
+	if computer == nil {
+		opponent = newSeat()
+	}
+	if creatorWhite {
+		lg.white, lg.black = creator, opponent
+	} else {
+		lg.white, lg.black = opponent, creator
+	}
+	lg.id = storage.SetGame(ctx, g)
+	liveGamesMu.Lock()
+	liveGames[lg.id] = lg
+	liveGamesMu.Unlock()
+	slog.InfoContext(ctx, "Game started", "game_id", lg.id, "computer", computer != nil, "clock", c != nil)
+	if c != nil {
+		go lg.runClock()
+	}
+	return lg
+}
+
+// getLiveGame returns the live state of a stored game, nil when there is no
+// such game. Games stored without the server have no seats, so nobody can
+// move in them.
+func getLiveGame(id int) *liveGame {
+	liveGamesMu.Lock()
+	defer liveGamesMu.Unlock()
+	if lg, ok := liveGames[id]; ok {
+		return lg
+	}
+	g := storage.GetGameById(id)
+	if g == nil {
+		return nil
+	}
+	lg := &liveGame{id: id, g: g, events: newBroadcaster()}
+	liveGames[id] = lg
+	return lg
+}
+
+// play applies a move of the side to move and publishes it. The caller
+// holds lg.mu.
+func (lg *liveGame) play(ctx context.Context, m game.Move) (game.Situation, error) {
+	now := time.Now()
+	if lg.g.Result == game.Ongoing && lg.clock != nil && lg.clock.flagged(now) {
+		lg.flag(now)
+	}
+	player := "human"
+	if lg.computer != nil && lg.computer.isWhite == lg.g.IsWhiteMove {
+		player = "computer"
+	}
+	started := time.Now()
+	situation, err := lg.g.Play(m)
+	moveValidation.Observe(time.Since(started).Seconds())
+	if err != nil {
+		return situation, err
+	}
+	lg.generation++
+	movesPlayed.Inc(player)
+	slog.DebugContext(ctx, "Move played", "game_id", lg.id, "player", player, "move", m.String(), "ply", len(lg.g.Moves))
+	if lg.clock != nil {
+		lg.clock.press(now)
+	}
+	lg.lastActive = now
+	lg.drawOfferBy = ""
+	lg.takebackBy = ""
+	lg.publish(gameEvent{Type: moveEvent, Move: m.String(), San: lg.g.LastMoveSAN(), Situation: situation, Board: convertBoard(lg.g)})
+	lg.publishResult()
+	return situation, nil
+}
+
+// computerReply lets the computer opponent move when it is its turn. The
+// engine thinks on a copy without holding the lock, so that clients can
+// still read the game meanwhile.
+func (lg *liveGame) computerReply(ctx context.Context) (game.Move, game.Situation, bool) {
+	lg.mu.Lock()
+	c := lg.computer
+	if c == nil || lg.g.Result != game.Ongoing || lg.g.IsWhiteMove != c.isWhite {
+		lg.mu.Unlock()
+		return game.Move{}, game.Continue, false
+	}
+	position, generation := lg.g.Clone(), lg.generation
+	lg.mu.Unlock()
+
+	started := time.Now()
+	m, err := engine.Think(position, c.limits())
+	engineThink.Observe(time.Since(started).Seconds())
+	if err != nil {
+		slog.ErrorContext(ctx, "Error thinking of a move", "game_id", lg.id, "error", err)
+		return game.Move{}, game.Continue, false
+	}
+	return lg.playReply(ctx, m, generation)
+}
+
+// playReply plays the move the computer thought of in the position of the
+// generation, unless the game changed while it was thinking.
+func (lg *liveGame) playReply(ctx context.Context, m game.Move, generation int) (game.Move, game.Situation, bool) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	if lg.generation != generation {
+		return game.Move{}, game.Continue, false
+	}
+	situation, err := lg.play(ctx, m)
+	if err != nil {
+		return game.Move{}, game.Continue, false
+	}
+	return m, situation, true
+}
+
+// offerDraw records a draw offer of the given colour. The caller holds lg.mu.
+func (lg *liveGame) offerDraw(color string) error {
+	if lg.g.Result != game.Ongoing {
+		return game.GameOver
+	}
+	lg.drawOfferBy = color
+	lg.publish(gameEvent{Type: drawOfferEvent, By: color})
+	return nil
+}
+
+// answerDraw accepts or declines the draw offered by the other colour. The
+// caller holds lg.mu.
+func (lg *liveGame) answerDraw(color string, accept bool) error {
+	if lg.g.Result != game.Ongoing {
+		return game.GameOver
+	}
+	if lg.drawOfferBy == "" || lg.drawOfferBy == color {
+		return NoDrawOffer
+	}
+	lg.drawOfferBy = ""
+	if !accept {
+		lg.publish(gameEvent{Type: drawDeclinedEvent, By: color})
+		return nil
+	}
+	lg.g.Finish(game.Draw)
+	lg.publishResult()
+	return nil
+}
+
+// flag ends the game when the side to move has run out of time. The caller
+// holds lg.mu.
+func (lg *liveGame) flag(now time.Time) {
+	if lg.g.IsWhiteMove {
+		lg.g.Finish(game.BlackWon)
+	} else {
+		lg.g.Finish(game.WhiteWon)
+	}
+	lg.clock.stop(now)
+	lg.publishResult()
+}
+
+// runClock publishes the clock every tick and flags the side to move when its
+// time is up. It returns once the game is over or evicted.
+func (lg *liveGame) runClock() {
+	ticker := time.NewTicker(clockTick)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		lg.mu.Lock()
+		if lg.g.Result != game.Ongoing || lg.evicted.Load() {
+			lg.mu.Unlock()
+			return
+		}
+		if lg.clock.flagged(now) {
+			lg.flag(now)
+			lg.mu.Unlock()
+			return
+		}
+		lg.publish(gameEvent{Type: clockEvent, Clock: lg.clock.response(now)})
+		lg.mu.Unlock()
+	}
+}
+
+func (lg *liveGame) publishResult() {
+	if lg.g.Result == game.Ongoing {
+		return
+	}
+	lg.lastActive = time.Now()
+	if lg.clock != nil {
+		lg.clock.stop(lg.lastActive)
+	}
+	lg.recordRatings()
+	lg.publish(gameEvent{Type: resultEvent, Situation: lg.g.Situation(), Result: resultName(lg.g.Result)})
+}
+
+// recordRatings updates the ratings of both players once a rated game
+// between two accounts has ended.
+func (lg *liveGame) recordRatings() {
+	if !lg.rated || lg.white == nil || lg.black == nil || lg.white.userId == 0 || lg.black.userId == 0 {
+		return
+	}
+	var whiteScore float64
+	switch lg.g.Result {
+	case game.WhiteWon:
+		whiteScore = 1
+	case game.Draw:
+		whiteScore = 0.5
+	}
+	ratings.RecordGame(lg.id, lg.category, lg.white.userId, lg.black.userId, whiteScore)
+}
+
+// publish fills in the state every event carries and hands it to the
+// broadcaster. The caller holds lg.mu.
+func (lg *liveGame) publish(e gameEvent) {
+	e.Ply = len(lg.g.Moves)
+	e.IsWhite = lg.g.IsWhiteMove
+	if e.Clock == nil && lg.clock != nil {
+		e.Clock = lg.clock.response(time.Now())
+	}
+	lg.events.publish(e)
+}
+
+func (lg *liveGame) clockResponse() *clockResponse {
+	if lg.clock == nil {
+		return nil
+	}
+	return lg.clock.response(time.Now())
+}
+
+// humanMove plays a move for the holder of the seat of the given colour. The
+// caller holds lg.mu.
+func (lg *liveGame) humanMove(ctx context.Context, isWhite bool, m game.Move) (game.Situation, error) {
+	if lg.g.Result == game.Ongoing && lg.g.IsWhiteMove != isWhite {
+		return game.Continue, NotYourTurn
+	}
+	return lg.play(ctx, m)
+}
+
+// humanMoveNotation plays a move given in coordinate or in standard algebraic
+// notation for the holder of the seat of the given colour. The caller holds
+// lg.mu.
+func (lg *liveGame) humanMoveNotation(ctx context.Context, isWhite bool, notation string) (game.Move, game.Situation, error) {
+	if lg.g.Result == game.Ongoing && lg.g.IsWhiteMove != isWhite {
+		return game.Move{}, game.Continue, NotYourTurn
+	}
+	m, err := lg.g.ParseNotation(notation)
+	if err != nil {
+		return game.Move{}, game.Continue, err
+	}
+	situation, err := lg.play(ctx, m)
+	return m, situation, err
+}
@@ -0,0 +1,358 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"lets-go-chess/game"
+	"lets-go-chess/ratings"
+	"log/slog"
+	"math"
+	mathrand "math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	seekTokenHeader = "X-Seek-Token"
+	matchInterval   = time.Second
+	defaultRating   = ratings.DefaultRating
+	standardVariant = "standard"
+	defaultSeekTtl  = 10 * time.Minute
+)
+
+var (
+	UnknownSeek      = errors.New("unknown seek")
+	InvalidSeekToken = errors.New("invalid seek token")
+	SeekMatched      = errors.New("seek already matched")
+)
+
+// seekRequest posts a seek to the lobby. Without a TimeControl the game has
+// no clock. Color is the preferred colour: "white", "black" or "random"
+// (default). The seeker only meets opponents rated between MinRating and
+// MaxRating, zero leaves a bound open. Rating is the rating of an anonymous
+// seeker, logged in seekers get the account's rating in the time control's
+// category.
+type seekRequest struct {
+	TimeControl *timeControlRequest `json:"timeControl,omitempty"`
+	Variant     string              `json:"variant" schema:"enum=standard"`
+	Rated       bool                `json:"rated"`
+	Color       string              `json:"color" schema:"enum=white|black|random"`
+	Rating      int                 `json:"rating" schema:"min=0"`
+	MinRating   int                 `json:"minRating,omitempty" schema:"min=0"`
+	MaxRating   int                 `json:"maxRating,omitempty" schema:"min=0"`
+}
+
+// seekResponse shows a seek. SeekToken is only returned on creation and Seat
+// only to the owner of a matched seek.
+type seekResponse struct {
+	seekRequest
+	SeekId    int           `json:"seekId"`
+	SeekToken string        `json:"seekToken,omitempty"`
+	Status    string        `json:"status"`
+	Seat      *seatResponse `json:"seat,omitempty"`
+}
+
+type seek struct {
+	seekRequest
+	id    int
+	token string
+	// userId is the logged in seeker, zero for anonymous ones
+	userId int
+	// client is the key the matched game counts for, see gameQuota
+	client  string
+	created time.Time
+	// seat is set once the seek has been matched
+	seat    *seatResponse
+	matched time.Time
+}
+
+// lobby keeps the seeks, the matcher pairs them into games. Seeks open for
+// longer than lobby.seekTtl expire, matched ones are dropped once their
+// owner has read the seat or, unread, after lobby.seekTtl.
+type lobby struct {
+	mu     sync.Mutex
+	nextId int
+	seeks  map[int]*seek
+	wake   chan struct{}
+}
+
+var defaultLobby = newLobby()
+
+func newLobby() *lobby {
+	return &lobby{nextId: 1, seeks: make(map[int]*seek), wake: make(chan struct{}, 1)}
+}
+
+func (l *lobby) add(req seekRequest, userId int, client string) *seek {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := &seek{seekRequest: req, id: l.nextId, token: rand.Text(), userId: userId, client: client, created: time.Now()}
+	l.nextId++
+	l.seeks[s.id] = s
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+	return s
+}
+
+// owned returns the seek if the token is the one of its owner.
+func (l *lobby) owned(id int, token string) (*seek, error) {
+	s := l.seeks[id]
+	if s == nil {
+		return nil, UnknownSeek
+	}
+	if subtle.ConstantTimeCompare([]byte(s.token), []byte(token)) != 1 {
+		return nil, InvalidSeekToken
+	}
+	return s, nil
+}
+
+func (l *lobby) cancel(id int, token string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, err := l.owned(id, token)
+	if err != nil {
+		return err
+	}
+	if s.seat != nil {
+		return SeekMatched
+	}
+	delete(l.seeks, id)
+	return nil
+}
+
+// open returns the seeks waiting for an opponent, oldest first.
+func (l *lobby) open() []*seek {
+	var seeks []*seek
+	for _, s := range l.seeks {
+		if s.seat == nil {
+			seeks = append(seeks, s)
+		}
+	}
+	slices.SortFunc(seeks, func(a, b *seek) int {
+		return a.id - b.id
+	})
+	return seeks
+}
+
+// run is the matcher, it pairs seeks whenever one is added and on every
+// interval.
+func (l *lobby) run() {
+	ticker := time.NewTicker(matchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.wake:
+		case <-ticker.C:
+		}
+		l.match()
+	}
+}
+
+// expire drops the open seeks waiting and the matched seeks unread for
+// longer than lobby.seekTtl.
+func (l *lobby) expire(now time.Time) {
+	ttl := seekTtl()
+	for id, s := range l.seeks {
+		if s.seat == nil && now.Sub(s.created) > ttl || s.seat != nil && now.Sub(s.matched) > ttl {
+			delete(l.seeks, id)
+		}
+	}
+}
+
+// match pairs open seeks, the oldest seek first takes the oldest compatible
+// opponent with whom a game can start.
+func (l *lobby) match() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire(time.Now())
+	seeks := l.open()
+	for i, a := range seeks {
+		if a.seat != nil {
+			continue
+		}
+		for _, b := range seeks[i+1:] {
+			if b.seat == nil && a.compatible(b) && startMatchedGame(a, b) {
+				break
+			}
+		}
+	}
+}
+
+// compatible tells whether two seeks make a game. Seeks of the same client,
+// the same user or anonymous ones from the same address, never meet, so
+// that nobody plays against themselves.
+func (a *seek) compatible(b *seek) bool {
+	sameClock := a.TimeControl == nil && b.TimeControl == nil ||
+		a.TimeControl != nil && b.TimeControl != nil && *a.TimeControl == *b.TimeControl
+	return sameClock &&
+		a.client != b.client &&
+		a.Variant == b.Variant &&
+		a.Rated == b.Rated &&
+		(a.Color == "random" || a.Color != b.Color) &&
+		a.accepts(b.Rating) && b.accepts(a.Rating)
+}
+
+func (a *seek) accepts(rating int) bool {
+	return (a.MinRating == 0 || rating >= a.MinRating) && (a.MaxRating == 0 || rating <= a.MaxRating)
+}
+
+// startMatchedGame creates the game of two compatible seeks and hands each
+// owner a seat. The game counts for both seekers, it does not start while
+// one of them has too many games.
+func startMatchedGame(a, b *seek) bool {
+	aWhite := a.Color == "white" || b.Color == "black"
+	if a.Color == "random" && b.Color == "random" {
+		aWhite = mathrand.IntN(2) == 0
+	}
+	c, err := newClockFor(a.TimeControl)
+	if err != nil {
+		slog.Error("Error creating clock", "error", err)
+		return false
+	}
+	lg, err := openGames.start([]string{a.client, b.client}, func() *liveGame {
+		return newLiveGame(context.Background(), game.StartGame(), aWhite, nil, c)
+	})
+	if err != nil {
+		return false
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	lg.rated = a.Rated
+	lg.timeControl = a.TimeControl
+	lg.category = a.TimeControl.category()
+	_, token, err := lg.claim(lg.seat(!aWhite).invite, nil)
+	if err != nil {
+		slog.Error("Error claiming seat", "game_id", lg.id, "error", err)
+		return false
+	}
+	lg.seat(aWhite).userId = a.userId
+	lg.seat(!aWhite).userId = b.userId
+	a.seat = &seatResponse{GameId: lg.id, Color: colorName(aWhite), SeatToken: lg.seat(aWhite).token}
+	b.seat = &seatResponse{GameId: lg.id, Color: colorName(!aWhite), SeatToken: token}
+	a.matched, b.matched = time.Now(), time.Now()
+	return true
+}
+
+func seekTtl() time.Duration {
+	if ttl := viper.GetDuration("lobby.seekTtl"); ttl > 0 {
+		return ttl
+	}
+	return defaultSeekTtl
+}
+
+func (s *seek) response() *seekResponse {
+	resp := &seekResponse{seekRequest: s.seekRequest, SeekId: s.id, Status: "open"}
+	if s.seat != nil {
+		resp.Status = "matched"
+	}
+	return resp
+}
+
+func createSeek(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var req seekRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "Error unmarshalling request", "error", err)
+		writeError(w, InvalidRequest, err.Error())
+		return
+	}
+	if req.Variant == "" {
+		req.Variant = standardVariant
+	}
+	if req.Color == "" {
+		req.Color = "random"
+	}
+	if req.Rating == 0 {
+		req.Rating = defaultRating
+	}
+	if _, err := newClockFor(req.TimeControl); err != nil {
+		writeError(w, err, "timeControl needs a positive initial time")
+		return
+	}
+	switch {
+	case req.Variant != standardVariant:
+		writeError(w, InvalidRequest, "only the standard variant is supported")
+		return
+	case req.Color != "white" && req.Color != "black" && req.Color != "random":
+		writeError(w, InvalidRequest, "color must be white, black or random")
+		return
+	case req.MaxRating != 0 && req.MaxRating < req.MinRating:
+		writeError(w, InvalidRequest, "maxRating must not be below minRating")
+		return
+	}
+	var userId int
+	if u := userFrom(r); u != nil {
+		userId = u.Id
+		req.Rating = int(math.Round(ratings.Get(u.Id, req.TimeControl.category()).Rating))
+	} else if req.Rated {
+		writeError(w, LoginRequired, "rated games need an account")
+		return
+	}
+	client := clientKey(r)
+	if err := openGames.check(client); err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	s := defaultLobby.add(req, userId, client)
+	resp := s.response()
+	resp.SeekToken = s.token
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, resp)
+}
+
+func listSeeks(w http.ResponseWriter, _ *http.Request) {
+	defaultLobby.mu.Lock()
+	defer defaultLobby.mu.Unlock()
+	resp := make([]*seekResponse, 0)
+	for _, s := range defaultLobby.open() {
+		resp = append(resp, s.response())
+	}
+	writeJSON(w, resp)
+}
+
+// getSeek shows a seek, its owner learns the seat of the matched game here.
+// The seek is gone once its owner has read the seat.
+func getSeek(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, InvalidRequest, "seek id must be a number")
+		return
+	}
+	defaultLobby.mu.Lock()
+	defer defaultLobby.mu.Unlock()
+	s, err := defaultLobby.owned(id, r.Header.Get(seekTokenHeader))
+	if errors.Is(err, UnknownSeek) {
+		writeError(w, err, nil)
+		return
+	}
+	resp := defaultLobby.seeks[id].response()
+	if err == nil && s.seat != nil {
+		resp.Seat = s.seat
+		delete(defaultLobby.seeks, id)
+	}
+	writeJSON(w, resp)
+}
+
+func cancelSeek(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, InvalidRequest, "seek id must be a number")
+		return
+	}
+	if err = defaultLobby.cancel(id, r.Header.Get(seekTokenHeader)); err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"lets-go-chess/logging"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const requestIdHeader = "X-Request-ID"
+
+// requestIdMiddleware gives every request an id, the one set by a proxy in
+// front of the server if it looks sane. It is answered in the header and
+// logged with everything done for the request.
+func requestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIdHeader)
+		if !validRequestId(id) {
+			id = logging.NewRequestId()
+		}
+		w.Header().Set(requestIdHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestId(r.Context(), id)))
+	})
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// noteError keeps err for the request log, for where the request is out of
+// reach.
+func noteError(w http.ResponseWriter, err error) {
+	if rec, ok := w.(*statusRecorder); ok {
+		rec.err = err
+	}
+}
+
+// logRequest logs a served request, with the game it was about if the
+// handler told. Latency is the whole time spent in the handler, for a live
+// stream how long it was followed.
+func logRequest(r *http.Request, route string, rec *statusRecorder) {
+	level := slog.LevelInfo
+	if rec.status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("route", route),
+		slog.Int("status", rec.status),
+		slog.Duration("latency", time.Since(rec.started)),
+	}
+	if rec.err != nil {
+		attrs = append(attrs, slog.String("error", rec.err.Error()))
+	}
+	slog.LogAttrs(r.Context(), level, "Request served", attrs...)
+}
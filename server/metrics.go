@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bufio"
+	"lets-go-chess/engine"
+	"lets-go-chess/game"
+	"lets-go-chess/metrics"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequests = metrics.Default.NewCounter("chess_http_requests_total",
+		"HTTP requests by route, method and status.", "route", "method", "status")
+	httpDuration = metrics.Default.NewHistogram("chess_http_request_duration_seconds",
+		"Latency of the HTTP requests by route and method, until the response is written or the connection upgraded.", nil, "route", "method")
+	movesPlayed = metrics.Default.NewCounter("chess_moves_total",
+		"Moves played by the players and the computer, its rate are the moves per second.", "player")
+	moveValidation = metrics.Default.NewHistogram("chess_move_validation_seconds",
+		"Time the game takes to check and apply a move.", []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01})
+	engineThink = metrics.Default.NewHistogram("chess_engine_think_seconds",
+		"Time the computer opponent takes to pick a move.", []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 3, 5, 10})
+	liveClients = metrics.Default.NewGauge("chess_live_clients",
+		"Clients following games over a WebSocket or server-sent events.", "transport")
+)
+
+func init() {
+	metrics.Default.NewGaugeFunc("chess_active_games", "Games in memory which are not over.", activeGames)
+	metrics.Default.NewCounterFunc("chess_engine_searches_total", "Searches of the engine.", func() float64 {
+		return float64(engine.ReadStats().Searches)
+	})
+	metrics.Default.NewCounterFunc("chess_engine_book_moves_total", "Moves the engine took from the opening book.", func() float64 {
+		return float64(engine.ReadStats().BookMoves)
+	})
+	metrics.Default.NewCounterFunc("chess_engine_tablebase_moves_total", "Moves the engine took from the endgame tablebases.", func() float64 {
+		return float64(engine.ReadStats().TablebaseMoves)
+	})
+	metrics.Default.NewCounterFunc("chess_engine_nodes_total", "Positions the engine searched.", func() float64 {
+		return float64(engine.ReadStats().Nodes)
+	})
+	metrics.Default.NewCounterFunc("chess_engine_depth_total", "Depth reached by the searches added up, divided by the searches it is the average depth.", func() float64 {
+		return float64(engine.ReadStats().Depth)
+	})
+	metrics.Default.NewCounterFunc("chess_engine_search_seconds_total", "Time the engine spent searching.", func() float64 {
+		return engine.ReadStats().SearchTime.Seconds()
+	})
+}
+
+// activeGames counts the live games still being played. The games are looked
+// at one by one, as elsewhere a game is locked before liveGamesMu.
+func activeGames() float64 {
+	liveGamesMu.Lock()
+	games := make([]*liveGame, 0, len(liveGames))
+	for _, lg := range liveGames {
+		games = append(games, lg)
+	}
+	liveGamesMu.Unlock()
+	active := 0
+	for _, lg := range games {
+		lg.mu.Lock()
+		if lg.g.Result == game.Ongoing {
+			active++
+		}
+		lg.mu.Unlock()
+	}
+	return float64(active)
+}
+
+// statusRecorder remembers the status and error a handler answers with and
+// observes the latency once the response starts, long-lived streams would
+// skew the histogram otherwise. Hijacking the connection counts as switching
+// protocols.
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	err     error
+	started time.Time
+	route   string
+	method  string
+}
+
+func (r *statusRecorder) respond(status int) {
+	if r.status == 0 {
+		r.status = status
+		httpDuration.Observe(time.Since(r.started).Seconds(), r.route, r.method)
+	}
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.respond(status)
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.respond(http.StatusOK)
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the flusher and deadlines of
+// the connection.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.respond(http.StatusSwitchingProtocols)
+	}
+	return conn, rw, err
+}
+
+// instrument counts the requests of a route, observes their latency and logs
+// them. The route is the pattern it is registered at, so that game ids do not
+// end up in the labels.
+func instrument(route string, handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, started: time.Now(), route: route, method: r.Method}
+		handler(rec, r)
+		// a handler writing nothing answers 200
+		rec.respond(http.StatusOK)
+		httpRequests.Inc(route, r.Method, strconv.Itoa(rec.status))
+		logRequest(r, route, rec)
+	}
+}
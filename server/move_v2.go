@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// moveV2Request is the body of the v2 move endpoint. Move is in coordinate
+// notation, e.g. "e2e4" or "e7e8q", or in standard algebraic notation, e.g.
+// "Nf3" or "O-O".
+type moveV2Request struct {
+	Move string `json:"move" schema:"required,minLength=2,maxLength=10"`
+}
+
+// moveV2Response is the gameResponse of a move together with the applied
+// move, and the reply of the computer opponent, in both notations.
+type moveV2Response struct {
+	gameResponse
+	San         string `json:"san"`
+	Uci         string `json:"uci"`
+	ComputerSan string `json:"computerSan,omitempty"`
+}
+
+// moveV2 plays a move given in algebraic notation in the game of the path,
+// the integer squares of move are easy to mix up.
+func moveV2(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var req moveV2Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "Error unmarshalling request", "error", err)
+		writeError(w, InvalidRequest, err.Error())
+		return
+	}
+	lg, ok := gameFromPath(w, r)
+	if !ok {
+		return
+	}
+	resp := &moveV2Response{}
+	lg.mu.Lock()
+	isWhite, err := lg.authorizeUser(r.Header.Get(seatTokenHeader), userFrom(r))
+	if err == nil {
+		m, situation, moveErr := lg.humanMoveNotation(r.Context(), isWhite, req.Move)
+		if err = moveErr; err == nil {
+			resp.Situation = situation
+			resp.Uci = m.String()
+			resp.San = lg.g.LastMoveSAN()
+		}
+	}
+	lg.mu.Unlock()
+	if err != nil {
+		writeError(w, err, req)
+		return
+	}
+	m, reply, replied := lg.computerReply(r.Context())
+	lg.mu.Lock()
+	if replied {
+		resp.ComputerMove = m.String()
+		resp.ComputerSan = lg.g.LastMoveSAN()
+		resp.Situation = reply
+	}
+	resp.GameId = lg.id
+	resp.IsWhite = lg.g.IsWhiteMove
+	resp.Board = convertBoard(lg.g)
+	resp.positionResponse = newPositionResponse(lg.g)
+	resp.Spectators = lg.spectators
+	lg.mu.Unlock()
+	writeJSON(w, resp)
+}
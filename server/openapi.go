@@ -0,0 +1,360 @@
+package server
+
+import (
+	"fmt"
+	"lets-go-chess/game"
+	"lets-go-chess/ratings"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const openAPIVersion = "3.0.3"
+
+// schema is the part of the OpenAPI schema object the API needs. The schemas
+// of the request and response types are derived from their json tags,
+// constraints beyond the Go type come from the schema tag, e.g.
+// `schema:"required,enum=white|black|random"` or `schema:"min=1"`.
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+type openAPIServer struct {
+	Url string `json:"url"`
+}
+
+type openAPIOperation struct {
+	OperationId string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Deprecated  bool    `json:"deprecated,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                      `json:"required"`
+	Content  map[string]openAPIContent `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                    `json:"description"`
+	Headers     map[string]*openAPIHeader `json:"headers,omitempty"`
+	Content     map[string]openAPIContent `json:"content,omitempty"`
+}
+
+type openAPIHeader struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type openAPIContent struct {
+	Schema *schema `json:"schema"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*schema                `json:"schemas"`
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// knownSchemas are the types whose schema does not follow from their kind.
+var knownSchemas = map[reflect.Type]*schema{
+	reflect.TypeFor[time.Time]():      {Type: "string", Format: "date-time"},
+	reflect.TypeFor[game.Situation](): {Type: "integer", Enum: []any{0, 1, 2, 3}, Description: "0 continue, 1 check, 2 checkmate, 3 stalemate"},
+	reflect.TypeFor[ratings.Category](): {
+		Type: "string",
+		Enum: []any{string(ratings.Bullet), string(ratings.Blitz), string(ratings.Rapid), string(ratings.Classical), string(ratings.Correspondence)},
+	},
+}
+
+// schemaOf returns the schema of t, named structs become components and are
+// referenced.
+func schemaOf(t reflect.Type, components map[string]*schema) *schema {
+	if s, ok := knownSchemas[t]; ok {
+		return s
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := schemaOf(t.Elem(), components)
+		if s.Ref != "" {
+			return &schema{AllOf: []*schema{s}, Nullable: true}
+		}
+		nullable := *s
+		nullable.Nullable = true
+		return &nullable
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &schema{Type: "array", Items: schemaOf(t.Elem(), components)}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), components)}
+	case reflect.Struct:
+		name := componentName(t)
+		if _, ok := components[name]; !ok {
+			// registered before the fields in case the type refers to itself
+			s := &schema{Type: "object", Properties: make(map[string]*schema), AdditionalProperties: false}
+			components[name] = s
+			addProperties(s, t, components)
+		}
+		return &schema{Ref: "#/components/schemas/" + name}
+	}
+	// interfaces, e.g. the details of an error, may hold anything
+	return &schema{}
+}
+
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// addProperties adds the json fields of the struct t to s. Fields of
+// embedded structs are promoted unless a shallower field has the name, as
+// encoding/json does.
+func addProperties(s *schema, t reflect.Type, components map[string]*schema) {
+	var embedded []reflect.Type
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			embedded = append(embedded, field.Type)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := schemaOf(field.Type, components)
+		if tag, ok := field.Tag.Lookup("schema"); ok {
+			property = constrain(property, tag, &s.Required, name)
+		}
+		s.Properties[name] = property
+	}
+	for _, e := range embedded {
+		promoted := &schema{Properties: make(map[string]*schema)}
+		addProperties(promoted, e, components)
+		for name, property := range promoted.Properties {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = property
+				if slices.Contains(promoted.Required, name) {
+					s.Required = append(s.Required, name)
+				}
+			}
+		}
+	}
+	slices.Sort(s.Required)
+}
+
+// constrain returns a copy of s with the constraints of a schema tag,
+// required fields are added to required. A malformed tag is a programming
+// error and panics.
+func constrain(s *schema, tag string, required *[]string, name string) *schema {
+	c := *s
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "required":
+			*required = append(*required, name)
+		case "enum":
+			for _, v := range strings.Split(value, "|") {
+				if c.Type == "integer" {
+					n, err := strconv.Atoi(v)
+					if err != nil {
+						panic(fmt.Sprintf("schema tag of %v: %v", name, err))
+					}
+					c.Enum = append(c.Enum, n)
+				} else {
+					c.Enum = append(c.Enum, v)
+				}
+			}
+		case "min", "max":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				panic(fmt.Sprintf("schema tag of %v: %v", name, err))
+			}
+			if key == "min" {
+				c.Minimum = &n
+			} else {
+				c.Maximum = &n
+			}
+		case "minLength", "maxLength":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				panic(fmt.Sprintf("schema tag of %v: %v", name, err))
+			}
+			if key == "minLength" {
+				c.MinLength = &n
+			} else {
+				c.MaxLength = &n
+			}
+		case "description":
+			c.Description = value
+		default:
+			panic(fmt.Sprintf("schema tag of %v: unknown option %q", name, key))
+		}
+	}
+	return &c
+}
+
+// newOpenAPIDocument describes the routes, error responses list the codes
+// of the errors a route answers with.
+func newOpenAPIDocument(routes []route) *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:   "lets-go-chess",
+			Version: "1",
+			Description: "Every failed request answers with an Error whose code is listed per response. " +
+				"The /v1 routes are also served without the prefix for older clients, which are not held to the request schemas.",
+		},
+		Servers: []openAPIServer{{Url: "/"}},
+		Paths:   make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: make(map[string]*schema),
+			SecuritySchemes: map[string]*openAPISecurityScheme{
+				"bearer":  {Type: "http", Scheme: "bearer"},
+				"session": {Type: "apiKey", In: "cookie", Name: sessionCookie},
+			},
+		},
+	}
+	errorSchema := schemaOf(reflect.TypeFor[errorResponse](), doc.Components.Schemas)
+	var codes []any
+	for _, e := range apiErrors {
+		codes = append(codes, e.code)
+	}
+	slices.SortFunc(codes, func(a, b any) int { return strings.Compare(a.(string), b.(string)) })
+	doc.Components.Schemas["ErrorResponse"].Properties["code"] = &schema{Type: "string", Enum: codes}
+
+	for _, rt := range routes {
+		path := rt.versionedPath()
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*openAPIOperation)
+		}
+		op := &openAPIOperation{
+			OperationId: rt.operation,
+			Summary:     rt.summary,
+			Tags:        []string{rt.tag},
+			Parameters:  rt.parameters(),
+			Responses:   make(map[string]*openAPIResponse),
+		}
+		switch rt.auth {
+		case optionalUser:
+			op.Security = []map[string][]string{{}, {"bearer": {}}, {"session": {}}}
+		case requiredUser:
+			op.Security = []map[string][]string{{"bearer": {}}, {"session": {}}}
+		}
+		if rt.request != nil {
+			op.RequestBody = &openAPIRequestBody{
+				Required: !rt.optionalBody,
+				Content:  map[string]openAPIContent{"application/json": {Schema: schemaOf(reflect.TypeOf(rt.request), doc.Components.Schemas)}},
+			}
+		}
+		success := &openAPIResponse{Description: http.StatusText(rt.successStatus())}
+		switch {
+		case rt.stream != "":
+			success.Content = map[string]openAPIContent{rt.stream: {Schema: schemaOf(reflect.TypeFor[gameEvent](), doc.Components.Schemas)}}
+		case rt.response != nil:
+			success.Content = map[string]openAPIContent{"application/json": {Schema: schemaOf(reflect.TypeOf(rt.response), doc.Components.Schemas)}}
+		}
+		op.Responses[strconv.Itoa(rt.successStatus())] = success
+		if rt.otherStatus != 0 {
+			op.Responses[strconv.Itoa(rt.otherStatus)] = &openAPIResponse{Description: http.StatusText(rt.otherStatus)}
+		}
+		if len(rt.socketErrors) > 0 {
+			var codes []string
+			for _, err := range rt.socketErrors {
+				codes = append(codes, apiErrors[err].code)
+			}
+			slices.Sort(codes)
+			op.Description = "Failed client messages are answered with an error event, its code is one of " + strings.Join(codes, ", ") + "."
+		}
+		for status, codes := range rt.errorCodes() {
+			resp := &openAPIResponse{
+				Description: strings.Join(codes, ", "),
+				Content:     map[string]openAPIContent{"application/json": {Schema: errorSchema}},
+			}
+			if slices.Contains(codes, apiErrors[TooManyRequests].code) {
+				resp.Headers = map[string]*openAPIHeader{
+					"Retry-After": {Description: "seconds until a rate limited request is served again", Schema: &schema{Type: "integer"}},
+				}
+			}
+			op.Responses[strconv.Itoa(status)] = resp
+		}
+		doc.Paths[path][strings.ToLower(rt.method)] = op
+	}
+	return doc
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  *openAPIDocument
+)
+
+// openAPI serves the description of the API routes.
+func openAPI(w http.ResponseWriter, _ *http.Request) {
+	openAPIOnce.Do(func() {
+		openAPIDoc = newOpenAPIDocument(apiRoutes)
+	})
+	writeJSON(w, openAPIDoc)
+}
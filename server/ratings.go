@@ -0,0 +1,63 @@
+package server
+
+import (
+	"errors"
+	"lets-go-chess/accounts"
+	"lets-go-chess/ratings"
+	"net/http"
+	"strconv"
+)
+
+var UnknownUser = errors.New("unknown user")
+
+// ratingHistoryResponse is the current rating of a user in one category and
+// the rating after each of their rated games, oldest first.
+type ratingHistoryResponse struct {
+	Category ratings.Category `json:"category"`
+	Current  ratings.Rating   `json:"current"`
+	History  []ratings.Entry  `json:"history"`
+}
+
+func userFromPath(w http.ResponseWriter, r *http.Request) (*accounts.User, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, InvalidRequest, "user id must be a number")
+		return nil, false
+	}
+	u := accounts.GetUserById(id)
+	if u == nil {
+		writeError(w, UnknownUser, nil)
+		return nil, false
+	}
+	return u, true
+}
+
+// userRatings lists the current rating of a user in every category.
+func userRatings(w http.ResponseWriter, r *http.Request) {
+	u, ok := userFromPath(w, r)
+	if !ok {
+		return
+	}
+	resp := make(map[ratings.Category]ratings.Rating, len(ratings.Categories))
+	for _, c := range ratings.Categories {
+		resp[c] = ratings.Get(u.Id, c)
+	}
+	writeJSON(w, resp)
+}
+
+func ratingHistory(w http.ResponseWriter, r *http.Request) {
+	u, ok := userFromPath(w, r)
+	if !ok {
+		return
+	}
+	c, err := ratings.ParseCategory(r.PathValue("category"))
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	history := ratings.History(u.Id, c)
+	if history == nil {
+		history = make([]ratings.Entry, 0)
+	}
+	writeJSON(w, &ratingHistoryResponse{Category: c, Current: ratings.Get(u.Id, c), History: history})
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"lets-go-chess/game"
+	"log/slog"
+	"net/http"
+)
+
+var (
+	GameNotOver    = errors.New("game not over")
+	NoRematchOffer = errors.New("no rematch to answer")
+	RematchStarted = errors.New("rematch already started")
+)
+
+// rematchRequest offers, accepts or declines a rematch. Action is one of
+// "offer", "accept" and "decline".
+type rematchRequest struct {
+	Action string `json:"action" schema:"required,enum=offer|accept|decline"`
+}
+
+// offerRematch offers the opponent a rematch once the game is over, the
+// computer opponent accepts right away. The caller holds lg.mu.
+func (lg *liveGame) offerRematch(ctx context.Context, color string) (*liveGame, error) {
+	switch {
+	case lg.g.Result == game.Ongoing:
+		return nil, GameNotOver
+	case lg.rematchId != 0:
+		return nil, RematchStarted
+	case lg.computer != nil:
+		return lg.startRematch(ctx)
+	}
+	lg.rematchBy = color
+	lg.publish(gameEvent{Type: rematchOfferEvent, By: color})
+	return nil, nil
+}
+
+// answerRematch accepts or declines the rematch offered by the other colour,
+// returning the new game when accepted. The caller holds lg.mu.
+func (lg *liveGame) answerRematch(ctx context.Context, color string, accept bool) (*liveGame, error) {
+	if lg.rematchId != 0 {
+		return nil, RematchStarted
+	}
+	if lg.rematchBy == "" || lg.rematchBy == color {
+		return nil, NoRematchOffer
+	}
+	if !accept {
+		lg.rematchBy = ""
+		lg.publish(gameEvent{Type: rematchDeclinedEvent, By: color})
+		return nil, nil
+	}
+	return lg.startRematch(ctx)
+}
+
+// startRematch creates the next game with swapped colours and the same
+// settings, and hands each player its new seat over the live channel. It
+// counts for the clients which started this game, the offer stays while one
+// of them has too many games. The caller holds lg.mu.
+func (lg *liveGame) startRematch(ctx context.Context) (*liveGame, error) {
+	// the time control was valid for this game already
+	c, _ := newClockFor(lg.timeControl)
+	creatorWhite := true
+	var computer *computerOpponent
+	if lg.computer != nil {
+		computer = &computerOpponent{isWhite: !lg.computer.isWhite, skill: lg.computer.skill}
+		creatorWhite = lg.computer.isWhite
+	}
+	next, err := openGames.start(lg.creators, func() *liveGame {
+		return newLiveGame(ctx, game.StartGame(), creatorWhite, computer, c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	next.mu.Lock()
+	next.timeControl = lg.timeControl
+	next.rated = lg.rated
+	next.category = lg.category
+	next.private = lg.private
+	next.rematchOf = lg.id
+	seats := make(map[bool]*seatResponse)
+	for _, isWhite := range []bool{true, false} {
+		s, previous := next.seat(isWhite), lg.seat(!isWhite)
+		if s == nil || previous == nil {
+			continue
+		}
+		s.invite = ""
+		s.userId = previous.userId
+		seats[!isWhite] = &seatResponse{GameId: next.id, Color: colorName(isWhite), SeatToken: s.token}
+	}
+	next.mu.Unlock()
+	if computer != nil && computer.isWhite {
+		go next.computerReply(context.WithoutCancel(ctx))
+	}
+
+	lg.rematchId = next.id
+	lg.rematchBy = ""
+	for isWhite, seat := range seats {
+		lg.publish(gameEvent{Type: rematchEvent, RematchId: next.id, Seat: seat, audience: colorName(isWhite)})
+	}
+	lg.publish(gameEvent{Type: rematchEvent, RematchId: next.id, audience: spectatorsRoom})
+	return next, nil
+}
+
+// rematch handles the rematch requests of the players. Starting the rematch
+// answers with the caller's seat in the new game, the opponent gets theirs
+// on the live channel.
+func rematch(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var req rematchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "Error unmarshalling request", "error", err)
+		writeError(w, InvalidRequest, err.Error())
+		return
+	}
+	lg, seated, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	if seated == nil {
+		writeError(w, SeatTokenRequired, nil)
+		return
+	}
+	color := colorName(*seated)
+	lg.mu.Lock()
+	var next *liveGame
+	var err error
+	switch req.Action {
+	case "offer":
+		next, err = lg.offerRematch(r.Context(), color)
+	case "accept", "decline":
+		next, err = lg.answerRematch(r.Context(), color, req.Action == "accept")
+	default:
+		lg.mu.Unlock()
+		writeError(w, InvalidRequest, "action must be offer, accept or decline")
+		return
+	}
+	lg.mu.Unlock()
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	if next == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	next.mu.Lock()
+	isWhite := !*seated
+	resp := &seatResponse{GameId: next.id, Color: colorName(isWhite), SeatToken: next.seat(isWhite).token}
+	next.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, resp)
+}
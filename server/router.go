@@ -1,13 +1,23 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"lets-go-chess/book"
 	"lets-go-chess/game"
-	"lets-go-chess/storage"
-	"log"
+	"lets-go-chess/logging"
+	"lets-go-chess/tablebase"
+	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/viper"
 )
@@ -17,60 +27,257 @@ type gameResponse struct {
 	GameId    int            `json:"gameId,omitempty"`
 	Situation game.Situation `json:"situation,omitempty"`
 	IsWhite   bool           `json:"isWhite"`
+	// ComputerMove is the reply of the computer opponent, if it moved
+	ComputerMove string `json:"computerMove,omitempty"`
+	// Color, SeatToken and InviteUrl are returned to the creator of a game:
+	// the token authorizes the moves of the creator's seat, the invite link
+	// lets the opponent claim the other one
+	Color     string `json:"color,omitempty"`
+	SeatToken string `json:"seatToken,omitempty"`
+	InviteUrl string `json:"inviteUrl,omitempty"`
+	// Spectators is the number of clients watching the live updates
+	Spectators int `json:"spectators"`
+	positionResponse
+}
+
+// positionResponse describes the position so that clients do not need chess
+// logic of their own to show it. CheckSquare is the square of the king in
+// check, Captured the pieces each side has lost and MaterialBalance white's
+// material minus black's in pawns.
+type positionResponse struct {
+	Fen             string            `json:"fen"`
+	LastMove        *lastMoveResponse `json:"lastMove,omitempty"`
+	CheckSquare     string            `json:"checkSquare,omitempty"`
+	Captured        capturedResponse  `json:"captured"`
+	MaterialBalance int               `json:"materialBalance"`
+	Ply             int               `json:"ply"`
+	MoveNumber      int               `json:"moveNumber"`
+	Result          string            `json:"result"`
+}
+
+type lastMoveResponse struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	San  string `json:"san"`
+	Uci  string `json:"uci"`
+}
+
+// capturedResponse lists the lost pieces of each side as board letters, most
+// valuable first.
+type capturedResponse struct {
+	White []string `json:"white"`
+	Black []string `json:"black"`
+}
+
+// startGameRequest is the optional body of startGame. Opponent is "human"
+// (default) or "computer", Color is the colour of the creator: "white"
+// (default), "black" or "random". The
+// computer plays at Level 1-20, or at the level closest to Elo. Without a
+// TimeControl the game has no clock. Only the players see a Private game.
+type startGameRequest struct {
+	Opponent    string              `json:"opponent"`
+	Level       int                 `json:"level"`
+	Elo         int                 `json:"elo"`
+	Color       string              `json:"color" schema:"enum=white|black|random"`
+	TimeControl *timeControlRequest `json:"timeControl,omitempty"`
+	Private     bool                `json:"private"`
+}
+
+// gameStateResponse is the full state of a game, enough for a client to
+// recover it after a page refresh. HalfmoveClock and FullmoveNumber are the
+// FEN counters, Clock the time left when the game has a time control.
+type gameStateResponse struct {
+	GameId         int            `json:"gameId"`
+	Board          [][]string     `json:"board"`
+	IsWhite        bool           `json:"isWhite"`
+	Situation      game.Situation `json:"situation"`
+	Moves          []string       `json:"moves"`
+	Castling       string         `json:"castling"`
+	EnPassant      string         `json:"enPassant,omitempty"`
+	HalfmoveClock  int            `json:"halfmoveClock"`
+	FullmoveNumber int            `json:"fullmoveNumber"`
+	Clock          *clockResponse `json:"clock,omitempty"`
+	DrawOfferBy    string         `json:"drawOfferBy,omitempty"`
+	TakebackBy     string         `json:"takebackBy,omitempty"`
+	RematchBy      string         `json:"rematchBy,omitempty"`
+	// RematchOf and RematchId link the game to the one before and after it
+	RematchOf int `json:"rematchOf,omitempty"`
+	RematchId int `json:"rematchId,omitempty"`
+	// WaitingForOpponent is set until the invited opponent claims the seat
+	WaitingForOpponent bool `json:"waitingForOpponent,omitempty"`
+	// White and Black are the logged in users playing the game
+	White      *userResponse `json:"white,omitempty"`
+	Black      *userResponse `json:"black,omitempty"`
+	Private    bool          `json:"private,omitempty"`
+	Spectators int           `json:"spectators"`
+	positionResponse
+}
+
+// legalMoveResponse is a move a client may play. Kind is one of "normal",
+// "capture", "castling", "enPassant" and "promotion", a capturing promotion
+// is a promotion with Capture set.
+type legalMoveResponse struct {
+	Move      string `json:"move"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Promotion string `json:"promotion,omitempty"`
+	Kind      string `json:"kind"`
+	Capture   bool   `json:"capture"`
+	Check     bool   `json:"check"`
+}
+
+type bookMoveResponse struct {
+	Move   string `json:"move"`
+	Weight int    `json:"weight"`
+}
+
+type tablebaseResponse struct {
+	Result   string `json:"result"`
+	MateIn   int    `json:"mateIn,omitempty"`
+	BestMove string `json:"bestMove,omitempty"`
 }
 
 type moveRequest struct {
-	GameId int `json:"gameId"`
-	FromX  int `json:"fromX"`
-	FromY  int `json:"fromY"`
-	ToX    int `json:"toX"`
-	ToY    int `json:"toY"`
+	GameId int `json:"gameId" schema:"required"`
+	FromX  int `json:"fromX" schema:"required"`
+	FromY  int `json:"fromY" schema:"required"`
+	ToX    int `json:"toX" schema:"required"`
+	ToY    int `json:"toY" schema:"required"`
 }
 
+// StartServer serves the API until the process is interrupted or
+// terminated, then shuts down gracefully.
 func StartServer() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("POST /startGame", corsMiddleware(startGame))
-	mux.HandleFunc("POST /move", corsMiddleware(move))
-	mux.HandleFunc("OPTIONS /move", corsMiddleware(nil))
+	storagePath := viper.GetString("storage.path")
+	if err := restoreGames(storagePath); err != nil {
+		slog.Error("Error restoring games", "error", err)
+		os.Exit(1)
+	}
+	mux := newRouter()
 
-	server := http.Server{
+	go defaultLobby.run()
+	go runEviction()
+
+	server := &http.Server{
 		Addr:         ":" + viper.GetString("server.port"),
 		WriteTimeout: viper.GetDuration("server.writeTimeout"),
 		ReadTimeout:  viper.GetDuration("server.readTimeout"),
 		IdleTimeout:  viper.GetDuration("server.idleTimeout"),
-		Handler:      mux,
+		Handler:      requestIdMiddleware(recoverMiddleware(mux)),
 	}
-	err := server.ListenAndServe()
+	listener, err := net.Listen("tcp", server.Addr)
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("Error listening", "addr", server.Addr, "error", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	serverLifecycle().ready.Store(true)
+
+	select {
+	case err = <-serveErr:
+		slog.Error("Error serving", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
 	}
+	// a second signal kills the process right away
+	stop()
+	slog.Info("Shutting down")
+	shutdown(server, viper.GetDuration("server.shutdownTimeout"), storagePath)
 }
 
 func corsMiddleware(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", viper.GetString("cors.frontend"))
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID, "+seatTokenHeader+", "+seekTokenHeader)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Expose-Headers", requestIdHeader+", Retry-After")
 		if handler != nil {
 			handler(w, r)
 		}
 	}
 }
 
-func startGame(w http.ResponseWriter, _ *http.Request) {
-	g := game.StartGame()
-	gameId := storage.SetGame(g)
+func startGame(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var req startGameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		slog.WarnContext(r.Context(), "Error unmarshalling request", "error", err)
+		writeError(w, InvalidRequest, err.Error())
+		return
+	}
+	var creatorWhite bool
+	switch req.Color {
+	case "", "white":
+		creatorWhite = true
+	case "black":
+	case "random":
+		creatorWhite = rand.IntN(2) == 0
+	default:
+		writeError(w, InvalidRequest, "color must be white, black or random")
+		return
+	}
+	var computer *computerOpponent
+	switch req.Opponent {
+	case "", "human":
+	case "computer":
+		var err error
+		if computer, err = newComputerOpponent(req, creatorWhite); err != nil {
+			writeError(w, err, req)
+			return
+		}
+	default:
+		writeError(w, InvalidOpponent, req)
+		return
+	}
+	c, err := newClockFor(req.TimeControl)
+	if err != nil {
+		writeError(w, err, "timeControl needs a positive initial time")
+		return
+	}
+
+	lg, err := openGames.start([]string{clientKey(r)}, func() *liveGame {
+		return newLiveGame(r.Context(), game.StartGame(), creatorWhite, computer, c)
+	})
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	logging.SetGameId(r.Context(), lg.id)
+	lg.mu.Lock()
+	lg.private = req.Private
+	lg.timeControl = req.TimeControl
+	if u := userFrom(r); u != nil {
+		lg.seat(creatorWhite).userId = u.Id
+	}
+	lg.mu.Unlock()
 	resp := &gameResponse{}
-	resp.GameId = gameId
-	resp.IsWhite = true
-	resp.Board = convertBoard(g)
+	if m, situation, ok := lg.computerReply(r.Context()); ok {
+		resp.ComputerMove = m.String()
+		resp.Situation = situation
+	}
+	lg.mu.Lock()
+	resp.GameId = lg.id
+	resp.IsWhite = lg.g.IsWhiteMove
+	resp.Board = convertBoard(lg.g)
+	resp.positionResponse = newPositionResponse(lg.g)
+	resp.Color = colorName(creatorWhite)
+	resp.SeatToken = lg.seat(creatorWhite).token
+	resp.InviteUrl = lg.inviteUrl()
+	lg.mu.Unlock()
 	marshal, err := json.Marshal(resp)
 	if err != nil {
-		log.Print("Error marshalling response", err)
+		slog.ErrorContext(r.Context(), "Error marshalling response", "error", err)
 	}
 	_, err = w.Write(marshal)
 	if err != nil {
-		log.Print("Error writing response", err)
+		slog.ErrorContext(r.Context(), "Error writing response", "error", err)
 	}
 }
 
@@ -79,30 +286,256 @@ func move(w http.ResponseWriter, r *http.Request) {
 
 	var req moveRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Print("Error unmarshalling request", err)
+		slog.WarnContext(r.Context(), "Error unmarshalling request", "error", err)
+		writeError(w, InvalidRequest, err.Error())
 		return
 	}
 
-	g := storage.GetGameById(req.GameId)
-	situation, err := g.NextMove(game.Position{X: req.FromX, Y: req.FromY}, game.Position{X: req.ToX, Y: req.ToY})
+	logging.SetGameId(r.Context(), req.GameId)
+	lg := getLiveGame(req.GameId)
+	if lg == nil {
+		writeError(w, UnknownGame, req)
+		return
+	}
+	lg.mu.Lock()
+	isWhite, err := lg.authorizeUser(r.Header.Get(seatTokenHeader), userFrom(r))
+	var situation game.Situation
+	if err == nil {
+		situation, err = lg.humanMove(r.Context(), isWhite, game.Move{From: game.Position{X: req.FromX, Y: req.FromY}, To: game.Position{X: req.ToX, Y: req.ToY}})
+	}
+	lg.mu.Unlock()
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, err, req)
 		return
 	}
 	resp := &gameResponse{}
 	resp.Situation = situation
-	resp.IsWhite = g.IsWhiteMove
-	resp.Board = convertBoard(g)
+	if m, reply, ok := lg.computerReply(r.Context()); ok {
+		resp.ComputerMove = m.String()
+		resp.Situation = reply
+	}
+	lg.mu.Lock()
+	resp.IsWhite = lg.g.IsWhiteMove
+	resp.Board = convertBoard(lg.g)
+	resp.positionResponse = newPositionResponse(lg.g)
+	resp.Spectators = lg.spectators
+	lg.mu.Unlock()
+	marshal, err := json.Marshal(resp)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error marshalling response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	_, err = w.Write(marshal)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error writing response", "error", err)
+	}
+}
+
+func gameState(w http.ResponseWriter, r *http.Request) {
+	lg, _, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	g := lg.g
+	resp := &gameStateResponse{
+		GameId:         lg.id,
+		Board:          convertBoard(g),
+		IsWhite:        g.IsWhiteMove,
+		Situation:      g.Situation(),
+		Moves:          make([]string, 0, len(g.Moves)),
+		Castling:       g.CastlingRights().String(),
+		HalfmoveClock:  g.HalfmoveClock(),
+		FullmoveNumber: g.FullmoveNumber(),
+		Clock:          lg.clockResponse(),
+		DrawOfferBy:    lg.drawOfferBy,
+		TakebackBy:     lg.takebackBy,
+		RematchBy:      lg.rematchBy,
+		RematchOf:      lg.rematchOf,
+		RematchId:      lg.rematchId,
+	}
+	resp.positionResponse = newPositionResponse(g)
+	resp.WaitingForOpponent = lg.inviteUrl() != ""
+	resp.White = lg.player(true)
+	resp.Black = lg.player(false)
+	resp.Private = lg.private
+	resp.Spectators = lg.spectators
+	for _, m := range g.Moves {
+		resp.Moves = append(resp.Moves, m.String())
+	}
+	if square, ok := g.EnPassantSquare(); ok {
+		resp.EnPassant = square.String()
+	}
+	writeJSON(w, resp)
+}
+
+func legalMoves(w http.ResponseWriter, r *http.Request) {
+	var from game.Position
+	if r.URL.Query().Has("from") {
+		var err error
+		if from, err = game.ParsePosition(r.URL.Query().Get("from")); err != nil {
+			writeError(w, InvalidRequest, "from must be a square like e2")
+			return
+		}
+	}
+	lg, _, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	g := lg.g
+	resp := make([]legalMoveResponse, 0)
+	for _, m := range g.LegalMoves() {
+		if from != (game.Position{}) && m.From != from {
+			continue
+		}
+		info := g.DescribeMove(m)
+		move := legalMoveResponse{
+			Move:    m.String(),
+			From:    m.From.String(),
+			To:      m.To.String(),
+			Kind:    moveKind(info),
+			Capture: info.Capture,
+			Check:   info.Check,
+		}
+		if m.Promotion != nil {
+			move.Promotion = strings.ToLower(game.PieceLetter(m.Promotion))
+		}
+		resp = append(resp, move)
+	}
+	writeJSON(w, resp)
+}
+
+func moveKind(info game.MoveInfo) string {
+	switch {
+	case info.Castling:
+		return "castling"
+	case info.EnPassant:
+		return "enPassant"
+	case info.Promotion != nil:
+		return "promotion"
+	case info.Capture:
+		return "capture"
+	}
+	return "normal"
+}
+
+func bookMoves(w http.ResponseWriter, r *http.Request) {
+	lg, _, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	g := lg.g
+	resp := make([]bookMoveResponse, 0)
+	for _, m := range book.Default().Moves(g) {
+		resp = append(resp, bookMoveResponse{Move: m.Move.String(), Weight: m.Weight})
+	}
+	writeJSON(w, resp)
+}
+
+func tablebaseLookup(w http.ResponseWriter, r *http.Request) {
+	lg, _, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	g := lg.g
+	tb := tablebase.Default()
+	result, ok := tb.Probe(g)
+	if !ok {
+		writeError(w, NotInTablebase, nil)
+		return
+	}
+	resp := &tablebaseResponse{}
+	switch result.Outcome {
+	case tablebase.Win:
+		resp.Result = "win"
+		resp.MateIn = result.MateIn()
+	case tablebase.Loss:
+		resp.Result = "loss"
+		resp.MateIn = result.MateIn()
+	default:
+		resp.Result = "draw"
+	}
+	if m, _, ok := tb.BestMove(g); ok {
+		resp.BestMove = m.String()
+	}
+	writeJSON(w, resp)
+}
+
+// gameFromPath looks up the game of the id path value, writing the error
+// response when there is none.
+func gameFromPath(w http.ResponseWriter, r *http.Request) (*liveGame, bool) {
+	gameId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, InvalidRequest, "game id must be a number")
+		return nil, false
+	}
+	logging.SetGameId(r.Context(), gameId)
+	lg := getLiveGame(gameId)
+	if lg == nil {
+		writeError(w, UnknownGame, map[string]int{"gameId": gameId})
+		return nil, false
+	}
+	return lg, true
+}
+
+func writeJSON(w http.ResponseWriter, resp any) {
+	w.Header().Set("Content-Type", "application/json")
 	marshal, err := json.Marshal(resp)
 	if err != nil {
-		log.Print("Error marshalling response", err)
+		noteError(w, err)
 		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 	_, err = w.Write(marshal)
 	if err != nil {
-		log.Print("Error writing response", err)
+		noteError(w, err)
+	}
+}
+
+func resultName(result game.Result) string {
+	switch result {
+	case game.WhiteWon:
+		return "whiteWon"
+	case game.BlackWon:
+		return "blackWon"
+	case game.Draw:
+		return "draw"
+	}
+	return "ongoing"
+}
+
+func newPositionResponse(g *game.Game) positionResponse {
+	resp := positionResponse{
+		Fen:             g.FEN(),
+		Captured:        capturedResponse{White: capturedLetters(g, true), Black: capturedLetters(g, false)},
+		MaterialBalance: g.MaterialBalance(),
+		Ply:             len(g.Moves),
+		MoveNumber:      g.FullmoveNumber(),
+		Result:          resultName(g.Result),
+	}
+	if len(g.Moves) > 0 {
+		m := g.Moves[len(g.Moves)-1]
+		resp.LastMove = &lastMoveResponse{From: m.From.String(), To: m.To.String(), San: g.LastMoveSAN(), Uci: m.String()}
+	}
+	if square, ok := g.CheckedKing(); ok {
+		resp.CheckSquare = square.String()
+	}
+	return resp
+}
+
+func capturedLetters(g *game.Game, isWhite bool) []string {
+	letters := make([]string, 0)
+	for _, mover := range g.CapturedPieces(isWhite) {
+		letters = append(letters, figureToLetter(&game.Figure{IsWhite: isWhite, Mover: mover}))
 	}
+	return letters
 }
 
 func convertBoard(g *game.Game) [][]string {
@@ -0,0 +1,375 @@
+package server
+
+import (
+	"context"
+	"lets-go-chess/accounts"
+	"lets-go-chess/game"
+	"lets-go-chess/metrics"
+	"lets-go-chess/ratings"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type authLevel int
+
+const (
+	public authLevel = iota
+	// optionalUser resolves the session if there is one
+	optionalUser
+	requiredUser
+)
+
+// route is an endpoint of the API. The route table is the contract with the
+// clients: it registers the handlers and is what /openapi.json describes.
+// Routes are served below /v<version>, version 1 routes also without the
+// prefix, or at legacy, for clients from before the versioned API.
+type route struct {
+	method    string
+	path      string
+	version   int
+	legacy    string
+	operation string
+	tag       string
+	summary   string
+	handler   func(w http.ResponseWriter, r *http.Request)
+	auth      authLevel
+	// request is the zero value of the JSON body, validated against its
+	// schema before the handler runs
+	request      any
+	optionalBody bool
+	// maxBody caps the request body in bytes unless limits.routes sets it,
+	// limits.maxBody applies if zero
+	maxBody int64
+	// response is the zero value of the JSON body answered with status
+	response any
+	status   int
+	// otherStatus is a second success status without a body
+	otherStatus int
+	// stream is the content type of a response streaming game events
+	stream string
+	// websocket routes upgrade the connection and are not for CORS requests
+	websocket bool
+	params    []*openAPIParameter
+	errors    []error
+	// socketErrors are sent as error events over the connection
+	socketErrors []error
+}
+
+var (
+	seatTokenParams = []*openAPIParameter{
+		{Name: seatTokenHeader, In: "header", Description: "seat token of a player", Schema: &schema{Type: "string"}},
+		{Name: "token", In: "query", Description: "seat token for clients which cannot set headers", Schema: &schema{Type: "string"}},
+	}
+	seatTokenHeaderParam = &openAPIParameter{Name: seatTokenHeader, In: "header", Description: "seat token of the player, not needed by a logged in player", Schema: &schema{Type: "string"}}
+	seekTokenParam       = &openAPIParameter{Name: seekTokenHeader, In: "header", Description: "seek token returned on creation", Schema: &schema{Type: "string"}}
+	watchErrors          = []error{UnknownGame, InvalidSeatToken, PrivateGame}
+	streamErrors         = append([]error{ShuttingDown}, watchErrors...)
+	seatErrors           = []error{UnknownGame, SeatTokenRequired, InvalidSeatToken, PrivateGame}
+	moveErrors           = []error{NotYourTurn, game.GameOver, game.InvalidFrom, game.ToOutOfBounds, game.MoveRulesViolation, game.WrongColor}
+)
+
+var apiRoutes = []route{
+	{
+		method: "POST", path: "/register", operation: "register", tag: "accounts",
+		summary: "Create an account", handler: register,
+		request: credentialsRequest{}, response: userResponse{}, status: http.StatusCreated,
+		errors: []error{accounts.InvalidName, accounts.InvalidPassword, accounts.NameTaken},
+	},
+	{
+		method: "POST", path: "/login", operation: "login", tag: "accounts",
+		summary: "Start a session, set as cookie and returned as bearer token", handler: login,
+		request: credentialsRequest{}, response: sessionResponse{},
+		errors: []error{accounts.InvalidCredentials},
+	},
+	{
+		method: "POST", path: "/logout", operation: "logout", tag: "accounts",
+		summary: "End the session", handler: logout, status: http.StatusNoContent,
+	},
+	{
+		method: "GET", path: "/me", operation: "me", tag: "accounts",
+		summary: "The logged in user", handler: me, auth: requiredUser, response: userResponse{},
+	},
+	{
+		method: "GET", path: "/users/{id}/ratings", operation: "userRatings", tag: "users",
+		summary: "Current ratings of a user per category", handler: userRatings,
+		response: map[ratings.Category]ratings.Rating{},
+		errors:   []error{UnknownUser},
+	},
+	{
+		method: "GET", path: "/users/{id}/ratings/{category}", operation: "ratingHistory", tag: "users",
+		summary: "Rating history of a user in a category", handler: ratingHistory,
+		response: ratingHistoryResponse{},
+		errors:   []error{UnknownUser, ratings.UnknownCategory},
+	},
+	{
+		method: "POST", path: "/users/{id}/block", operation: "blockUser", tag: "users",
+		summary: "Hide the chat of a user", handler: blockUser, auth: requiredUser, status: http.StatusNoContent,
+		errors: []error{UnknownUser},
+	},
+	{
+		method: "DELETE", path: "/users/{id}/block", operation: "unblockUser", tag: "users",
+		summary: "Show the chat of a blocked user again", handler: blockUser, auth: requiredUser, status: http.StatusNoContent,
+		errors: []error{UnknownUser},
+	},
+	{
+		method: "POST", path: "/startGame", operation: "startGame", tag: "games",
+		summary: "Start a game against a human or the computer", handler: startGame, auth: optionalUser,
+		request: startGameRequest{}, optionalBody: true, response: gameResponse{},
+		errors: []error{InvalidOpponent, TooManyOpenGames},
+	},
+	{
+		method: "POST", path: "/move", operation: "move", tag: "games",
+		summary: "Play a move given by coordinates", handler: move, auth: optionalUser,
+		request: moveRequest{}, maxBody: maxMoveBody, response: gameResponse{},
+		params: []*openAPIParameter{seatTokenHeaderParam},
+		errors: append([]error{UnknownGame, SeatTokenRequired, InvalidSeatToken}, moveErrors...),
+	},
+	{
+		method: "POST", path: "/games/{id}/move", version: 2, operation: "moveV2", tag: "games",
+		summary: "Play a move given in UCI or SAN", handler: moveV2, auth: optionalUser,
+		request: moveV2Request{}, maxBody: maxMoveBody, response: moveV2Response{},
+		params: []*openAPIParameter{seatTokenHeaderParam},
+		errors: append([]error{UnknownGame, SeatTokenRequired, InvalidSeatToken, game.InvalidNotation, game.AmbiguousMove}, moveErrors...),
+	},
+	{
+		method: "GET", path: "/games/{id}", operation: "gameState", tag: "games",
+		summary: "Full state of a game", handler: gameState, auth: optionalUser,
+		response: gameStateResponse{}, params: seatTokenParams, errors: watchErrors,
+	},
+	{
+		method: "POST", path: "/games/{id}/join", operation: "joinGame", tag: "games",
+		summary: "Claim the open seat of a game with its invite", handler: joinGame, auth: optionalUser,
+		response: seatResponse{},
+		params: []*openAPIParameter{
+			{Name: "invite", In: "query", Required: true, Description: "invite of the open seat", Schema: &schema{Type: "string"}},
+		},
+		errors: []error{UnknownGame, InvalidInvite},
+	},
+	{
+		method: "GET", path: "/games/{id}/ws", operation: "liveUpdates", tag: "games",
+		summary: "WebSocket of the game events, players send their moves, offers and chat on it",
+		handler: liveUpdates, auth: optionalUser, websocket: true, status: http.StatusSwitchingProtocols,
+		params: append([]*openAPIParameter{
+			{Name: "lastEventId", In: "query", Description: "id of the last event seen, the events after it are replayed", Schema: &schema{Type: "integer"}},
+			{Name: "since", In: "query", Description: "last ply seen, the events after it are replayed; plies repeat after a takeback, use lastEventId", Deprecated: true, Schema: &schema{Type: "integer"}},
+		}, seatTokenParams...),
+		errors: streamErrors,
+		socketErrors: append([]error{
+			SeatTokenRequired, NoDrawOffer, NoTakebackOffer, game.NothingToUndo, GameNotOver, NoRematchOffer, RematchStarted,
+			InvalidChatMessage, ChatRateLimited, LoginRequired, game.InvalidNotation, game.AmbiguousMove,
+		}, moveErrors...),
+	},
+	{
+		method: "GET", path: "/games/{id}/events", operation: "gameEvents", tag: "games",
+		summary: "Server-sent events of the game", handler: gameEvents, auth: optionalUser, stream: "text/event-stream",
+		params: append([]*openAPIParameter{
+			{Name: "Last-Event-ID", In: "header", Description: "id of the last event seen", Schema: &schema{Type: "integer"}},
+			{Name: "lastEventId", In: "query", Description: "Last-Event-ID of the first request", Schema: &schema{Type: "integer"}},
+		}, seatTokenParams...),
+		errors: streamErrors,
+	},
+	{
+		method: "POST", path: "/games/{id}/takeback", operation: "takeback", tag: "games",
+		summary: "Request, accept or decline taking back the last move", handler: takeback, auth: optionalUser,
+		request: takebackRequest{}, status: http.StatusNoContent, params: seatTokenParams,
+		errors: append([]error{NoTakebackOffer, game.NothingToUndo, game.GameOver}, seatErrors...),
+	},
+	{
+		method: "POST", path: "/games/{id}/rematch", operation: "rematch", tag: "games",
+		summary: "Offer, accept or decline a rematch, the new game's seat is returned once it starts",
+		handler: rematch, auth: optionalUser,
+		request: rematchRequest{}, response: seatResponse{}, status: http.StatusCreated, otherStatus: http.StatusNoContent,
+		params: seatTokenParams,
+		errors: append([]error{GameNotOver, NoRematchOffer, RematchStarted, TooManyOpenGames}, seatErrors...),
+	},
+	{
+		method: "GET", path: "/games/{id}/chat", operation: "chatHistory", tag: "chat",
+		summary: "Chat of the game the caller may see", handler: chatHistory, auth: optionalUser,
+		response: []chatMessage{}, params: seatTokenParams, errors: watchErrors,
+	},
+	{
+		method: "POST", path: "/games/{id}/chat", operation: "postChat", tag: "chat",
+		summary: "Send a chat message, spectators need to be logged in", handler: postChat, auth: optionalUser,
+		request: chatRequest{}, status: http.StatusNoContent, params: seatTokenParams,
+		errors: append([]error{InvalidChatMessage, ChatRateLimited, LoginRequired}, watchErrors...),
+	},
+	{
+		method: "POST", path: "/games/{id}/mute", operation: "muteChat", tag: "chat",
+		summary: "Hide the chat of the opponent", handler: muteChat, auth: optionalUser,
+		status: http.StatusNoContent, params: seatTokenParams, errors: seatErrors,
+	},
+	{
+		method: "DELETE", path: "/games/{id}/mute", operation: "unmuteChat", tag: "chat",
+		summary: "Show the chat of the opponent again", handler: muteChat, auth: optionalUser,
+		status: http.StatusNoContent, params: seatTokenParams, errors: seatErrors,
+	},
+	{
+		method: "GET", path: "/games/{id}/moves", operation: "legalMoves", tag: "analysis",
+		summary: "Legal moves of the side to move", handler: legalMoves, auth: optionalUser,
+		response: []legalMoveResponse{},
+		params: append([]*openAPIParameter{
+			{Name: "from", In: "query", Description: "only the moves of the piece on this square, e.g. e2", Schema: &schema{Type: "string"}},
+		}, seatTokenParams...),
+		errors: watchErrors,
+	},
+	{
+		method: "GET", path: "/games/{id}/book", operation: "bookMoves", tag: "analysis",
+		summary: "Opening book moves of the position", handler: bookMoves, auth: optionalUser,
+		response: []bookMoveResponse{}, params: seatTokenParams, errors: watchErrors,
+	},
+	{
+		method: "GET", path: "/games/{id}/tablebase", legacy: "/game/{id}/tablebase", operation: "tablebaseLookup", tag: "analysis",
+		summary: "Endgame tablebase result of the position", handler: tablebaseLookup, auth: optionalUser,
+		response: tablebaseResponse{}, params: seatTokenParams,
+		errors: append([]error{NotInTablebase}, watchErrors...),
+	},
+	{
+		method: "GET", path: "/lobby/seeks", operation: "listSeeks", tag: "lobby",
+		summary: "Open seeks", handler: listSeeks, response: []seekResponse{},
+	},
+	{
+		method: "POST", path: "/lobby/seeks", operation: "createSeek", tag: "lobby",
+		summary: "Seek an opponent, rated seeks need an account", handler: createSeek, auth: optionalUser,
+		request: seekRequest{}, response: seekResponse{}, status: http.StatusCreated,
+		errors: []error{LoginRequired, TooManyOpenGames},
+	},
+	{
+		method: "GET", path: "/lobby/seeks/{id}", operation: "getSeek", tag: "lobby",
+		summary: "A seek, its owner gets the seat once it is matched", handler: getSeek,
+		response: seekResponse{}, params: []*openAPIParameter{seekTokenParam},
+		errors: []error{UnknownSeek},
+	},
+	{
+		method: "DELETE", path: "/lobby/seeks/{id}", operation: "cancelSeek", tag: "lobby",
+		summary: "Cancel a seek", handler: cancelSeek, status: http.StatusNoContent,
+		params: []*openAPIParameter{seekTokenParam},
+		errors: []error{UnknownSeek, InvalidSeekToken, SeekMatched},
+	},
+}
+
+// newRouter registers the routes with their middlewares, a CORS preflight
+// for each path, the OpenAPI document, the probes and the metrics.
+func newRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	preflight := make(map[string]bool)
+	for _, rt := range apiRoutes {
+		h := rt.handlerFunc()
+		for _, path := range rt.paths() {
+			if path == rt.versionedPath() {
+				mux.HandleFunc(rt.method+" "+path, instrument(path, h))
+			} else {
+				mux.HandleFunc(rt.method+" "+path, instrument(path, legacyPath(h)))
+			}
+			if !rt.websocket && !preflight[path] {
+				mux.HandleFunc("OPTIONS "+path, corsMiddleware(nil))
+				preflight[path] = true
+			}
+		}
+	}
+	mux.HandleFunc("GET /openapi.json", corsMiddleware(openAPI))
+	mux.HandleFunc("GET /healthz", healthz)
+	mux.HandleFunc("GET /readyz", readyz)
+	mux.Handle("GET /metrics", metrics.Default)
+	return mux
+}
+
+// handlerFunc wraps the handler in the middlewares of the route, the
+// session is resolved before the rate is limited and the body validated.
+func (rt route) handlerFunc() func(w http.ResponseWriter, r *http.Request) {
+	limits := rt.limits()
+	h := rt.handler
+	if rt.request != nil {
+		h = validateBody(rt.request, rt.optionalBody, limits.maxBody, h)
+	}
+	h = limitRate(newLimiter(limits.rate, limits.burst), h)
+	switch rt.auth {
+	case optionalUser:
+		h = authMiddleware(false, h)
+	case requiredUser:
+		h = authMiddleware(true, requireUser(h))
+	}
+	if !rt.websocket {
+		h = corsMiddleware(h)
+	}
+	return h
+}
+
+// legacyPath marks the requests of a path from before the versioned API,
+// see validateBody.
+func legacyPath(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r.WithContext(context.WithValue(r.Context(), legacyContextKey{}, true)))
+	}
+}
+
+func (rt route) versionedPath() string {
+	return "/v" + strconv.Itoa(max(rt.version, 1)) + rt.path
+}
+
+// paths are the paths the route is served at.
+func (rt route) paths() []string {
+	paths := []string{rt.versionedPath()}
+	switch {
+	case rt.version > 1:
+	case rt.legacy != "":
+		paths = append(paths, rt.legacy)
+	default:
+		paths = append(paths, rt.path)
+	}
+	return paths
+}
+
+func (rt route) successStatus() int {
+	if rt.status == 0 {
+		return http.StatusOK
+	}
+	return rt.status
+}
+
+var pathParam = regexp.MustCompile(`{(\w+)}`)
+
+// parameters are the path parameters of the route followed by its own.
+func (rt route) parameters() []*openAPIParameter {
+	var params []*openAPIParameter
+	for _, m := range pathParam.FindAllStringSubmatch(rt.path, -1) {
+		p := &openAPIParameter{Name: m[1], In: "path", Required: true, Schema: &schema{Type: "integer"}}
+		if m[1] == "category" {
+			p.Schema = knownSchemas[reflect.TypeFor[ratings.Category]()]
+		}
+		params = append(params, p)
+	}
+	return append(params, rt.params...)
+}
+
+// errorCodes groups the codes of the errors the route answers with by their
+// status, including those of the middlewares and of malformed requests.
+func (rt route) errorCodes() map[int][]string {
+	errs := append([]error{InternalError, TooManyRequests}, rt.errors...)
+	if rt.request != nil || strings.Contains(rt.path, "{") {
+		errs = append(errs, InvalidRequest)
+	}
+	if rt.request != nil {
+		errs = append(errs, BodyTooLarge)
+	}
+	switch rt.auth {
+	case optionalUser:
+		errs = append(errs, accounts.InvalidSession)
+	case requiredUser:
+		errs = append(errs, accounts.InvalidSession, LoginRequired)
+	}
+	codes := make(map[int][]string)
+	for _, err := range errs {
+		e := apiErrors[err]
+		if !slices.Contains(codes[e.status], e.code) {
+			codes[e.status] = append(codes[e.status], e.code)
+		}
+	}
+	for _, c := range codes {
+		slices.Sort(c)
+	}
+	return codes
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"lets-go-chess/accounts"
+	"net/http"
+	"strconv"
+)
+
+const seatTokenHeader = "X-Seat-Token"
+
+var (
+	SeatTokenRequired = errors.New("seat token required")
+	InvalidSeatToken  = errors.New("invalid seat token")
+	InvalidInvite     = errors.New("invalid or already used invite")
+)
+
+// seat is one side of a game. Its holder proves it with the secret token,
+// or by being logged in as the user of the seat. An unclaimed seat can be
+// claimed once with the invite code, a seat of the computer opponent has
+// neither. muted hides the opponent's chat from the seat's player.
+type seat struct {
+	token  string
+	invite string
+	userId int
+	muted  bool
+}
+
+type seatResponse struct {
+	GameId    int    `json:"gameId"`
+	Color     string `json:"color"`
+	SeatToken string `json:"seatToken"`
+}
+
+func newSeat() *seat {
+	return &seat{token: rand.Text(), invite: rand.Text()}
+}
+
+func colorName(isWhite bool) string {
+	if isWhite {
+		return "white"
+	}
+	return "black"
+}
+
+// seat returns the seat of the given colour.
+func (lg *liveGame) seat(isWhite bool) *seat {
+	if isWhite {
+		return lg.white
+	}
+	return lg.black
+}
+
+// authorize returns the colour of the seat the token belongs to. The caller
+// holds lg.mu.
+func (lg *liveGame) authorize(token string) (isWhite bool, err error) {
+	if token == "" {
+		return false, SeatTokenRequired
+	}
+	for _, isWhite := range []bool{true, false} {
+		s := lg.seat(isWhite)
+		if s != nil && s.invite == "" && subtle.ConstantTimeCompare([]byte(s.token), []byte(token)) == 1 {
+			return isWhite, nil
+		}
+	}
+	return false, InvalidSeatToken
+}
+
+// authorizeUser returns the colour of the seat of a logged in user, falling
+// back to the seat token when there is none. The caller holds lg.mu.
+func (lg *liveGame) authorizeUser(token string, u *accounts.User) (isWhite bool, err error) {
+	if token == "" && u != nil {
+		for _, isWhite := range []bool{true, false} {
+			if s := lg.seat(isWhite); s != nil && s.invite == "" && s.userId == u.Id {
+				return isWhite, nil
+			}
+		}
+	}
+	return lg.authorize(token)
+}
+
+// claim hands the seat matching the invite to the caller, linking it to the
+// user when one is logged in. The caller holds lg.mu.
+func (lg *liveGame) claim(invite string, u *accounts.User) (isWhite bool, token string, err error) {
+	for _, isWhite := range []bool{true, false} {
+		s := lg.seat(isWhite)
+		if s != nil && s.invite != "" && subtle.ConstantTimeCompare([]byte(s.invite), []byte(invite)) == 1 {
+			s.invite = ""
+			if u != nil {
+				s.userId = u.Id
+			}
+			lg.publish(gameEvent{Type: joinEvent, By: colorName(isWhite)})
+			return isWhite, s.token, nil
+		}
+	}
+	return false, "", InvalidInvite
+}
+
+// inviteUrl is the link the opponent follows to claim the free seat, empty
+// when there is none.
+func (lg *liveGame) inviteUrl() string {
+	for _, isWhite := range []bool{true, false} {
+		if s := lg.seat(isWhite); s != nil && s.invite != "" {
+			return "/games/" + strconv.Itoa(lg.id) + "/join?invite=" + s.invite
+		}
+	}
+	return ""
+}
+
+func joinGame(w http.ResponseWriter, r *http.Request) {
+	lg, ok := gameFromPath(w, r)
+	if !ok {
+		return
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	isWhite, token, err := lg.claim(r.URL.Query().Get("invite"), userFrom(r))
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	writeJSON(w, &seatResponse{GameId: lg.id, Color: colorName(isWhite), SeatToken: token})
+}
+
+// player returns the user holding the seat, nil for anonymous players and
+// the computer.
+func (lg *liveGame) player(isWhite bool) *userResponse {
+	s := lg.seat(isWhite)
+	if s == nil || s.userId == 0 {
+		return nil
+	}
+	u := accounts.GetUserById(s.userId)
+	if u == nil {
+		return nil
+	}
+	return &userResponse{UserId: u.Id, Name: u.Name}
+}
@@ -0,0 +1,321 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"lets-go-chess/game"
+	"lets-go-chess/ratings"
+	"lets-go-chess/storage"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ShuttingDown = errors.New("server is shutting down")
+
+// lifecycle tracks whether the server takes traffic and the live streams,
+// which a shutdown has to end itself: the http.Server neither knows the
+// hijacked WebSocket connections nor ends the event streams.
+type lifecycle struct {
+	ready    atomic.Bool
+	mu       sync.Mutex
+	stopped  bool
+	stopping chan struct{}
+	streams  sync.WaitGroup
+}
+
+// currentLifecycle is the lifecycle of the server, a stream keeps the one
+// it was opened with.
+var currentLifecycle atomic.Pointer[lifecycle]
+
+func init() {
+	currentLifecycle.Store(newLifecycle())
+}
+
+func serverLifecycle() *lifecycle {
+	return currentLifecycle.Load()
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{stopping: make(chan struct{})}
+}
+
+// openStream counts a live stream, false once the server is shutting down.
+// Every opened stream is closed with closeStream.
+func (l *lifecycle) openStream() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return false
+	}
+	l.streams.Add(1)
+	return true
+}
+
+func (l *lifecycle) closeStream() {
+	l.streams.Done()
+}
+
+// stop takes the server out of rotation and tells the live streams to end.
+func (l *lifecycle) stop() {
+	l.ready.Store(false)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.stopped {
+		l.stopped = true
+		close(l.stopping)
+	}
+}
+
+// wait returns once the live streams have ended or ctx is done.
+func (l *lifecycle) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		l.streams.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+// healthz tells that the process serves requests at all.
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, &healthResponse{Status: "ok"})
+}
+
+// readyz tells whether the server takes new games and streams, it fails
+// while starting and once a shutdown has begun.
+func readyz(w http.ResponseWriter, _ *http.Request) {
+	if !serverLifecycle().ready.Load() {
+		writeError(w, ShuttingDown, nil)
+		return
+	}
+	writeJSON(w, &healthResponse{Status: "ready"})
+}
+
+// shutdown stops accepting connections, waits for the requests in flight
+// and the live streams to end, then flushes the games to storage.
+func shutdown(server *http.Server, timeout time.Duration, storagePath string) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	l := serverLifecycle()
+	l.stop()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Error draining requests", "error", err)
+		server.Close()
+	}
+	if err := l.wait(ctx); err != nil {
+		slog.Error("Error draining live streams", "error", err)
+	}
+	if err := flushGames(storagePath); err != nil {
+		slog.Error("Error flushing games", "path", storagePath, "error", err)
+	}
+}
+
+// savedLiveGame is the state of a liveGame kept across restarts next to the
+// stored game.
+type savedLiveGame struct {
+	White       *savedSeat          `json:"white,omitempty"`
+	Black       *savedSeat          `json:"black,omitempty"`
+	Computer    *savedComputer      `json:"computer,omitempty"`
+	Clock       *savedClock         `json:"clock,omitempty"`
+	TimeControl *timeControlRequest `json:"timeControl,omitempty"`
+	Rated       bool                `json:"rated,omitempty"`
+	Category    ratings.Category    `json:"category,omitempty"`
+	Private     bool                `json:"private,omitempty"`
+	RematchOf   int                 `json:"rematchOf,omitempty"`
+	RematchId   int                 `json:"rematchId,omitempty"`
+	Creators    []string            `json:"creators,omitempty"`
+}
+
+// savedSeat leaves out the user of the seat: accounts are not kept across
+// restarts and their ids start over, so a new account could take over the
+// seats of an old one. Players get their seats back by the seat token.
+type savedSeat struct {
+	Token  string `json:"token"`
+	Invite string `json:"invite,omitempty"`
+	Muted  bool   `json:"muted,omitempty"`
+}
+
+type savedComputer struct {
+	IsWhite bool `json:"isWhite"`
+	Skill   int  `json:"skill"`
+}
+
+// savedClock is the time left when the game was saved, the time the server
+// is down is not charged to the side to move.
+type savedClock struct {
+	WhiteMs     int64 `json:"whiteMs"`
+	BlackMs     int64 `json:"blackMs"`
+	IncrementMs int64 `json:"incrementMs"`
+	WhiteToMove bool  `json:"whiteToMove"`
+	Running     bool  `json:"running"`
+}
+
+// flushGames saves the state of the live games and writes the storage to
+// path. The games stay locked until they are written, in the order of
+// their ids as a rematch locks its game before the new one.
+func flushGames(path string) error {
+	if path == "" {
+		return nil
+	}
+	liveGamesMu.Lock()
+	games := make([]*liveGame, 0, len(liveGames))
+	for _, lg := range liveGames {
+		games = append(games, lg)
+	}
+	liveGamesMu.Unlock()
+	slices.SortFunc(games, func(a, b *liveGame) int { return a.id - b.id })
+	now := time.Now()
+	for _, lg := range games {
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		state, err := json.Marshal(lg.saved(now))
+		if err != nil {
+			return err
+		}
+		storage.SetState(lg.id, state)
+	}
+	return storage.Flush(path)
+}
+
+// restoreGames loads the storage flushed to path and brings back the live
+// games saved with it. Their clocks run again from the time left.
+func restoreGames(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := storage.Load(path); err != nil {
+		return err
+	}
+	var created []*liveGame
+	liveGamesMu.Lock()
+	defer func() {
+		// the quota is locked before liveGamesMu
+		liveGamesMu.Unlock()
+		for _, lg := range created {
+			openGames.add(lg.creators, lg)
+		}
+	}()
+	for _, id := range storage.GameIds() {
+		state := storage.GetState(id)
+		if state == nil {
+			continue
+		}
+		var saved savedLiveGame
+		if err := json.Unmarshal(state, &saved); err != nil {
+			return err
+		}
+		lg := restoreLiveGame(id, &saved, time.Now())
+		liveGames[id] = lg
+		if len(lg.creators) > 0 {
+			created = append(created, lg)
+		}
+		if lg.g.Result != game.Ongoing {
+			continue
+		}
+		if lg.clock != nil {
+			go lg.runClock()
+		}
+		// the computer may have been thinking when the server stopped
+		go lg.computerReply(context.Background())
+	}
+	return nil
+}
+
+// saved returns the state to keep of the game. The caller holds lg.mu.
+func (lg *liveGame) saved(now time.Time) *savedLiveGame {
+	s := &savedLiveGame{
+		White:       lg.white.saved(),
+		Black:       lg.black.saved(),
+		TimeControl: lg.timeControl,
+		Rated:       lg.rated,
+		Category:    lg.category,
+		Private:     lg.private,
+		RematchOf:   lg.rematchOf,
+		RematchId:   lg.rematchId,
+		Creators:    lg.creators,
+	}
+	if lg.computer != nil {
+		s.Computer = &savedComputer{IsWhite: lg.computer.isWhite, Skill: lg.computer.skill}
+	}
+	if c := lg.clock; c != nil {
+		white, black := c.remaining(now)
+		s.Clock = &savedClock{
+			WhiteMs:     white.Milliseconds(),
+			BlackMs:     black.Milliseconds(),
+			IncrementMs: c.increment.Milliseconds(),
+			WhiteToMove: c.whiteToMove,
+			Running:     !c.turnStarted.IsZero(),
+		}
+	}
+	return s
+}
+
+func (s *seat) saved() *savedSeat {
+	if s == nil {
+		return nil
+	}
+	return &savedSeat{Token: s.token, Invite: s.invite, Muted: s.muted}
+}
+
+func restoreLiveGame(id int, s *savedLiveGame, now time.Time) *liveGame {
+	lg := &liveGame{
+		id:          id,
+		g:           storage.GetGameById(id),
+		white:       s.White.restore(),
+		black:       s.Black.restore(),
+		timeControl: s.TimeControl,
+		rated:       s.Rated,
+		category:    s.Category,
+		private:     s.Private,
+		rematchOf:   s.RematchOf,
+		rematchId:   s.RematchId,
+		events:      newBroadcaster(),
+		creators:    restoredCreators(s.Creators),
+		lastActive:  now,
+	}
+	if s.Computer != nil {
+		lg.computer = &computerOpponent{isWhite: s.Computer.IsWhite, skill: s.Computer.Skill}
+	}
+	if s.Clock != nil {
+		lg.clock = &clock{
+			white:       time.Duration(s.Clock.WhiteMs) * time.Millisecond,
+			black:       time.Duration(s.Clock.BlackMs) * time.Millisecond,
+			increment:   time.Duration(s.Clock.IncrementMs) * time.Millisecond,
+			whiteToMove: s.Clock.WhiteToMove,
+		}
+		if s.Clock.Running {
+			lg.clock.turnStarted = now
+		}
+	}
+	return lg
+}
+
+func (s *savedSeat) restore() *seat {
+	if s == nil {
+		return nil
+	}
+	return &seat{token: s.Token, invite: s.Invite, muted: s.Muted}
+}
+
+// restoredCreators leaves out the users among the creators of a game, as
+// their ids may be reused by new accounts.
+func restoredCreators(creators []string) []string {
+	return slices.DeleteFunc(creators, func(client string) bool {
+		return strings.HasPrefix(client, "user:")
+	})
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+)
+
+var PrivateGame = errors.New("private game")
+
+// watchGame looks up the game of the id path value and the seat of the
+// caller, given by the X-Seat-Token header, the token query for clients that
+// cannot set headers, or the logged in user. seated is nil for spectators,
+// who are turned away from private games.
+func watchGame(w http.ResponseWriter, r *http.Request) (lg *liveGame, seated *bool, ok bool) {
+	lg, ok = gameFromPath(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	token := r.Header.Get(seatTokenHeader)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	u := userFrom(r)
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	if token != "" || u != nil {
+		isWhite, err := lg.authorizeUser(token, u)
+		switch {
+		case err == nil:
+			seated = &isWhite
+		case token != "":
+			writeError(w, err, nil)
+			return nil, nil, false
+		}
+	}
+	if seated == nil && lg.private {
+		writeError(w, PrivateGame, nil)
+		return nil, nil, false
+	}
+	return lg, seated, true
+}
+
+// watch counts a spectator of the live updates until leave is called.
+func (lg *liveGame) watch() (leave func()) {
+	lg.mu.Lock()
+	lg.spectators++
+	lg.mu.Unlock()
+	return func() {
+		lg.mu.Lock()
+		lg.spectators--
+		lg.mu.Unlock()
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"lets-go-chess/game"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+var NoTakebackOffer = errors.New("no takeback to answer")
+
+// takebackRequest asks for, accepts or declines a takeback. Action is one of
+// "request", "accept" and "decline".
+type takebackRequest struct {
+	Action string `json:"action" schema:"required,enum=request|accept|decline"`
+}
+
+// takebackPlies is how many moves a takeback of color removes: its last move,
+// and the opponent's reply if there is one already.
+func (lg *liveGame) takebackPlies(color string) int {
+	if colorName(!lg.g.IsWhiteMove) == color {
+		return 1
+	}
+	return 2
+}
+
+// requestTakeback asks the opponent to take back the last move of color.
+// The computer opponent agrees right away. The caller holds lg.mu.
+func (lg *liveGame) requestTakeback(color string) error {
+	if lg.g.Result != game.Ongoing {
+		return game.GameOver
+	}
+	if len(lg.g.Moves) < lg.takebackPlies(color) {
+		return game.NothingToUndo
+	}
+	if lg.computer != nil {
+		return lg.takeBack(color)
+	}
+	lg.takebackBy = color
+	lg.publish(gameEvent{Type: takebackOfferEvent, By: color})
+	return nil
+}
+
+// answerTakeback accepts or declines the takeback requested by the other
+// colour. The caller holds lg.mu.
+func (lg *liveGame) answerTakeback(color string, accept bool) error {
+	if lg.g.Result != game.Ongoing {
+		return game.GameOver
+	}
+	if lg.takebackBy == "" || lg.takebackBy == color {
+		return NoTakebackOffer
+	}
+	by := lg.takebackBy
+	lg.takebackBy = ""
+	if !accept {
+		lg.publish(gameEvent{Type: takebackDeclinedEvent, By: color})
+		return nil
+	}
+	return lg.takeBack(by)
+}
+
+// takeBack rolls the game back to before the last move of color and hands
+// the turn back to it. The caller holds lg.mu.
+func (lg *liveGame) takeBack(color string) error {
+	now := time.Now()
+	if lg.clock != nil && lg.clock.flagged(now) {
+		lg.flag(now)
+		return game.GameOver
+	}
+	plies := lg.takebackPlies(color)
+	lg.generation++
+	for range plies {
+		if err := lg.g.Undo(); err != nil {
+			return err
+		}
+	}
+	if lg.clock != nil {
+		lg.clock.takeBack(now, plies, len(lg.g.Moves) == 0)
+	}
+	lg.drawOfferBy = ""
+	lg.publish(gameEvent{Type: takebackEvent, By: color, Situation: lg.g.Situation(), Board: convertBoard(lg.g)})
+	return nil
+}
+
+// takeback handles the takeback requests of the seat holders, the same as
+// the takeback messages on the WebSocket.
+func takeback(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var req takebackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "Error unmarshalling request", "error", err)
+		writeError(w, InvalidRequest, err.Error())
+		return
+	}
+	lg, seated, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	if seated == nil {
+		writeError(w, SeatTokenRequired, nil)
+		return
+	}
+	color := colorName(*seated)
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	var err error
+	switch req.Action {
+	case "request":
+		err = lg.requestTakeback(color)
+	case "accept", "decline":
+		err = lg.answerTakeback(color, req.Action == "accept")
+	default:
+		writeError(w, InvalidRequest, "action must be request, accept or decline")
+		return
+	}
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAccountsAndSessions(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /register", register)
+	mux.HandleFunc("POST /login", login)
+	mux.HandleFunc("POST /logout", logout)
+	mux.HandleFunc("GET /me", authMiddleware(true, requireUser(me)))
+	mux.HandleFunc("POST /startGame", authMiddleware(false, startGame))
+	mux.HandleFunc("POST /move", authMiddleware(false, move))
+	do := func(method, path, body string, prepare func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if prepare != nil {
+			prepare(req)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	credentials := `{"name":"anna","password":"secret password"}`
+	if rec := do(http.MethodPost, "/register", credentials, nil); rec.Code != http.StatusCreated ||
+		rec.Result().Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("register expected 201 with JSON, got: %d %v %v", rec.Code, rec.Result().Header, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/register", credentials, nil); rec.Code != http.StatusConflict {
+		t.Errorf("register of a taken name expected 409, got: %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/login", `{"name":"anna","password":"wrong"}`, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("login with a wrong password expected 401, got: %d", rec.Code)
+	}
+	rec := do(http.MethodPost, "/login", credentials, nil)
+	var session sessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &session); err != nil || session.Token == "" {
+		t.Fatalf("login expected a session, got: %d %v", rec.Code, rec.Body.String())
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != session.Token || !cookies[0].HttpOnly {
+		t.Errorf("login expected an HttpOnly session cookie, got: %v", cookies)
+	}
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+session.Token) }
+	withCookie := func(r *http.Request) { r.AddCookie(cookies[0]) }
+
+	if rec = do(http.MethodGet, "/me", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("me without a session expected 401, got: %d", rec.Code)
+	}
+	if rec = do(http.MethodGet, "/me", "", withCookie); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"anna"`) {
+		t.Errorf("me with the cookie expected anna, got: %d %v", rec.Code, rec.Body.String())
+	}
+
+	rec = do(http.MethodPost, "/startGame", "", bearer)
+	var started gameResponse
+	json.Unmarshal(rec.Body.Bytes(), &started)
+	lg := getLiveGame(started.GameId)
+	if lg == nil || lg.player(true) == nil || lg.player(true).Name != "anna" {
+		t.Fatalf("startGame expected anna to hold the white seat")
+	}
+	move := `{"gameId":` + strconv.Itoa(started.GameId) + `,"fromX":5,"fromY":2,"toX":5,"toY":4}`
+	if rec = do(http.MethodPost, "/move", move, bearer); rec.Code != http.StatusOK {
+		t.Errorf("move by the logged in seat holder expected 200, got: %d %v", rec.Code, rec.Body.String())
+	}
+
+	do(http.MethodPost, "/logout", "", bearer)
+	if rec = do(http.MethodGet, "/me", "", bearer); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "invalid_session") {
+		t.Errorf("me after logout expected 401 invalid_session, got: %d %v", rec.Code, rec.Body.String())
+	}
+	rec = do(http.MethodPost, "/startGame", "", withCookie)
+	json.Unmarshal(rec.Body.Bytes(), &started)
+	if rec.Code != http.StatusOK || getLiveGame(started.GameId).player(true) != nil {
+		t.Errorf("startGame with a stale session expected an anonymous game, got: %d %v", rec.Code, rec.Body.String())
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != sessionCookie || cookies[0].MaxAge >= 0 {
+		t.Errorf("startGame with a stale session expected the cookie to be cleared, got: %v", cookies)
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/accounts"
+	"lets-go-chess/game"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
+)
+
+func TestChat(t *testing.T) {
+	viper.Set("chat.bannedWords", []string{"darn"})
+	defer viper.Set("chat.bannedWords", nil)
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	_, blackToken, _ := lg.claim(lg.black.invite, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{id}/ws", liveUpdates)
+	mux.HandleFunc("GET /games/{id}/chat", chatHistory)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/games/" + strconv.Itoa(lg.id) + "/ws"
+	white := dial(t, url+"?token="+lg.white.token)
+	black := dial(t, url+"?token="+blackToken)
+	spectator := dial(t, url)
+	for _, conn := range []*websocket.Conn{white, black, spectator} {
+		receiveType(t, conn, joinEvent)
+	}
+
+	send(t, white, clientMessage{Type: "chat", Text: " Darn, good luck! "})
+	if e := receive(t, black); e.Type != chatEvent || e.Chat.Room != playersRoom || e.Chat.From != "white" || e.Chat.Text != "****, good luck!" {
+		t.Errorf("expected the censored chat of white, got: %+v %+v", e, e.Chat)
+	}
+	receive(t, white)
+	send(t, spectator, clientMessage{Type: "chat", Text: "hi"})
+	if e := receive(t, spectator); e.Type != errorEvent || e.Error.Code != "login_required" {
+		t.Errorf("expected login_required for an anonymous spectator, got: %+v", e)
+	}
+	send(t, white, clientMessage{Type: "chat", Text: strings.Repeat("a", maxChatLength+1)})
+	if e := receive(t, white); e.Type != errorEvent || e.Error.Code != "invalid_chat_message" {
+		t.Errorf("expected invalid_chat_message, got: %+v", e)
+	}
+
+	// black's own message arrives once the mute is through
+	send(t, black, clientMessage{Type: "mute"})
+	send(t, black, clientMessage{Type: "chat", Text: "thanks"})
+	receive(t, black)
+	receive(t, white)
+	send(t, white, clientMessage{Type: "chat", Text: "muted"})
+	receive(t, white)
+	send(t, black, clientMessage{Type: "chat", Text: "ok"})
+	if e := receive(t, black); e.Type != chatEvent || e.Chat.Text != "ok" {
+		t.Errorf("expected muted chat to be hidden, got: %+v %+v", e, e.Chat)
+	}
+	for range chatBurst - 2 {
+		send(t, white, clientMessage{Type: "chat", Text: "spam"})
+	}
+	send(t, white, clientMessage{Type: "chat", Text: "spam"})
+	if e := receiveType(t, white, errorEvent); e.Error.Code != "chat_rate_limited" {
+		t.Errorf("expected chat_rate_limited, got: %+v", e)
+	}
+	// the spectator only ever saw the join
+	send(t, white, clientMessage{Type: "move", Move: "e2e4"})
+	if e := receive(t, spectator); e.Type != moveEvent {
+		t.Errorf("expected the spectator to see no players' chat, got: %+v", e)
+	}
+
+	history := func(token string) []chatMessage {
+		req := httptest.NewRequest(http.MethodGet, "/games/"+strconv.Itoa(lg.id)+"/chat", nil)
+		if token != "" {
+			req.Header.Set(seatTokenHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var resp []chatMessage
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+	if h := history(lg.white.token); len(h) != chatBurst+2 || h[0].Text != "****, good luck!" {
+		t.Errorf("GET chat expected every stored message of the players, got: %+v", h)
+	}
+	if h := history(blackToken); len(h) != 2 {
+		t.Errorf("GET chat expected the muted messages to be hidden, got: %+v", h)
+	}
+	if h := history(""); len(h) != 0 {
+		t.Errorf("GET chat expected no players' chat for spectators, got: %+v", h)
+	}
+}
+
+func TestChatBlock(t *testing.T) {
+	troll, _ := accounts.Register("troll", "correct horse")
+	reader, _ := accounts.Register("reader", "correct horse")
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	c := &chatMessage{Room: spectatorsRoom, userId: troll.Id}
+	v := chatViewer{userId: reader.Id}
+	if !lg.sees(v, c) {
+		t.Errorf("sees() expected the spectator to see the spectators' room")
+	}
+	accounts.Block(reader.Id, troll.Id)
+	if lg.sees(v, c) {
+		t.Errorf("sees() expected blocked users to be hidden")
+	}
+	accounts.Unblock(reader.Id, troll.Id)
+	if !lg.sees(v, c) {
+		t.Errorf("sees() expected unblocked users to be shown")
+	}
+}
+
+func TestCensor(t *testing.T) {
+	viper.Set("chat.bannedWords", []string{"darn"})
+	defer viper.Set("chat.bannedWords", nil)
+	if text := censor("darn it, Darn"); text != "**** it, ****" {
+		t.Errorf("censor expected the banned word replaced, got: %v", text)
+	}
+	banned := bannedWords.banned
+	if censor("darn"); bannedWords.banned != banned {
+		t.Error("censor expected the expression to be compiled once")
+	}
+	viper.Set("chat.bannedWords", []string{"heck"})
+	if text := censor("darn, heck"); text != "darn, ****" {
+		t.Errorf("censor expected the changed words, got: %v", text)
+	}
+	viper.Set("chat.bannedWords", nil)
+	if text := censor("heck"); text != "heck" {
+		t.Errorf("censor expected no words to be banned, got: %v", text)
+	}
+}
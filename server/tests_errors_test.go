@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/game"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMoveErrors(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	white := lg.white.token
+	_, black, _ := lg.claim(lg.black.invite, nil)
+	gameId := lg.id
+	tests := []struct {
+		body    string
+		token   string
+		eStatus int
+		eCode   string
+	}{
+		{body: `{"gameId":`, token: white, eStatus: http.StatusBadRequest, eCode: "invalid_request"},
+		{body: `{"gameId":%d,"fromX":5,"fromY":2,"toX":5,"toY":4}`, eStatus: http.StatusUnauthorized, eCode: "seat_token_required"},
+		{body: `{"gameId":%d,"fromX":5,"fromY":2,"toX":5,"toY":4}`, token: "guess", eStatus: http.StatusForbidden, eCode: "invalid_seat_token"},
+		{body: `{"gameId":%d,"fromX":5,"fromY":7,"toX":5,"toY":5}`, token: black, eStatus: http.StatusConflict, eCode: "not_your_turn"},
+		{body: `{"gameId":-1,"fromX":5,"fromY":2,"toX":5,"toY":4}`, eStatus: http.StatusNotFound, eCode: "unknown_game"},
+		{body: `{"gameId":%d,"fromX":5,"fromY":4,"toX":5,"toY":5}`, token: white, eStatus: http.StatusUnprocessableEntity, eCode: "invalid_from"},
+		{body: `{"gameId":%d,"fromX":5,"fromY":2,"toX":5,"toY":9}`, token: white, eStatus: http.StatusUnprocessableEntity, eCode: "to_out_of_bounds"},
+		{body: `{"gameId":%d,"fromX":5,"fromY":2,"toX":5,"toY":5}`, token: white, eStatus: http.StatusUnprocessableEntity, eCode: "move_rules_violation"},
+		{body: `{"gameId":%d,"fromX":5,"fromY":7,"toX":5,"toY":5}`, token: white, eStatus: http.StatusUnprocessableEntity, eCode: "wrong_color"},
+	}
+	for _, test := range tests {
+		body := strings.ReplaceAll(test.body, "%d", strconv.Itoa(gameId))
+		req := httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(body))
+		req.Header.Set(seatTokenHeader, test.token)
+		rec := httptest.NewRecorder()
+		recoverMiddleware(http.HandlerFunc(move)).ServeHTTP(rec, req)
+		var resp errorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("move(%v) expected an error envelope, got: %v", body, rec.Body.String())
+		}
+		if rec.Code != test.eStatus || resp.Code != test.eCode || resp.Message == "" {
+			t.Errorf("move(%v) expected %d %v, got: %d %+v", body, test.eStatus, test.eCode, rec.Code, resp)
+		}
+	}
+}
+
+func TestGameOverError(t *testing.T) {
+	g := game.StartGame()
+	g.Finish(game.Draw)
+	lg := newLiveGame(context.Background(), g, true, nil, nil)
+	body := `{"gameId":` + strconv.Itoa(lg.id) + `,"fromX":5,"fromY":2,"toX":5,"toY":4}`
+	req := httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(body))
+	req.Header.Set(seatTokenHeader, lg.white.token)
+	rec := httptest.NewRecorder()
+	move(rec, req)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"game_over"`) {
+		t.Errorf("move() on a finished game expected 409 game_over, got: %d %v", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	handler := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), `"internal_error"`) {
+		t.Errorf("recoverMiddleware() expected 500 internal_error, got: %d %v", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/game"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func getLegalMoves(t *testing.T, path string) (int, map[string]legalMoveResponse) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{id}/moves", legalMoves)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		return rec.Code, nil
+	}
+	var resp []legalMoveResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("GET %v expected the moves, got: %v", path, rec.Body.String())
+	}
+	moves := make(map[string]legalMoveResponse, len(resp))
+	for _, m := range resp {
+		moves[m.Move] = m
+	}
+	return rec.Code, moves
+}
+
+func TestLegalMoves(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	lg.mu.Lock()
+	// white may castle, take on d5 and take f5 en passant
+	for _, notation := range []string{"e2e4", "d7d5", "g1f3", "a7a6", "f1c4", "a6a5", "e4e5", "f7f5"} {
+		m, _ := game.ParseMove(notation)
+		if _, err := lg.play(context.Background(), m); err != nil {
+			t.Fatalf("play %v expected to succeed, got: %v", notation, err)
+		}
+	}
+	lg.mu.Unlock()
+	path := "/games/" + strconv.Itoa(lg.id) + "/moves"
+
+	code, moves := getLegalMoves(t, path)
+	if code != http.StatusOK {
+		t.Fatalf("GET moves expected 200, got: %d", code)
+	}
+	for move, expected := range map[string]legalMoveResponse{
+		"e1g1": {Move: "e1g1", From: "e1", To: "g1", Kind: "castling"},
+		"e5f6": {Move: "e5f6", From: "e5", To: "f6", Kind: "enPassant", Capture: true},
+		"c4d5": {Move: "c4d5", From: "c4", To: "d5", Kind: "capture", Capture: true},
+		"c4b5": {Move: "c4b5", From: "c4", To: "b5", Kind: "normal", Check: true},
+		"a2a3": {Move: "a2a3", From: "a2", To: "a3", Kind: "normal"},
+	} {
+		if moves[move] != expected {
+			t.Errorf("expected %+v, got: %+v", expected, moves[move])
+		}
+	}
+
+	code, moves = getLegalMoves(t, path+"?from=e5")
+	if _, ok := moves["e5f6"]; code != http.StatusOK || len(moves) != 2 || !ok {
+		t.Errorf("GET moves from e5 expected e5e6 and e5f6, got: %d %v", code, moves)
+	}
+	if code, _ = getLegalMoves(t, path+"?from=e9"); code != http.StatusBadRequest {
+		t.Errorf("GET moves from e9 expected 400, got: %d", code)
+	}
+	if code, _ = getLegalMoves(t, "/games/0/moves"); code != http.StatusNotFound {
+		t.Errorf("GET moves of an unknown game expected 404, got: %d", code)
+	}
+}
+
+func TestLegalMovesPromotion(t *testing.T) {
+	g := game.StartGame()
+	g.Field = game.Board{Cells: make(map[game.Position]*game.Figure)}
+	for x := 1; x <= 8; x++ {
+		for y := 1; y <= 8; y++ {
+			g.Field.Cells[game.Position{X: x, Y: y}] = nil
+		}
+	}
+	g.Field.Cells[game.Position{X: 5, Y: 1}] = &game.Figure{IsWhite: true, HasMoved: true, Mover: game.King{}}
+	g.Field.Cells[game.Position{X: 2, Y: 7}] = &game.Figure{IsWhite: true, HasMoved: true, Mover: game.Pawn{}}
+	g.Field.Cells[game.Position{X: 8, Y: 8}] = &game.Figure{IsWhite: false, HasMoved: true, Mover: game.King{}}
+	lg := newLiveGame(context.Background(), g, true, nil, nil)
+
+	code, moves := getLegalMoves(t, "/games/"+strconv.Itoa(lg.id)+"/moves?from=b7")
+	if code != http.StatusOK || len(moves) != 4 {
+		t.Fatalf("GET moves from b7 expected the four promotions, got: %d %v", code, moves)
+	}
+	expected := legalMoveResponse{Move: "b7b8q", From: "b7", To: "b8", Promotion: "q", Kind: "promotion", Check: true}
+	if moves["b7b8q"] != expected {
+		t.Errorf("expected %+v, got: %+v", expected, moves["b7b8q"])
+	}
+	if m := moves["b7b8n"]; m.Kind != "promotion" || m.Promotion != "n" || m.Check {
+		t.Errorf("expected the knight promotion without check, got: %+v", m)
+	}
+}
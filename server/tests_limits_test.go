@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/game"
+	"lets-go-chess/storage"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(1, 2)
+	now := time.Now()
+	for i := range 2 {
+		if wait := l.take("a", now); wait != 0 {
+			t.Errorf("request %d within the burst expected to pass, got wait %v", i, wait)
+		}
+	}
+	if wait := l.take("a", now); wait != time.Second {
+		t.Errorf("request above the burst expected to wait a second, got: %v", wait)
+	}
+	if wait := l.take("b", now); wait != 0 {
+		t.Errorf("another client expected its own bucket, got wait %v", wait)
+	}
+	if wait := l.take("a", now.Add(time.Second)); wait != 0 {
+		t.Errorf("request after a second expected to pass, got wait %v", wait)
+	}
+	l.take("a", now.Add(2*bucketSweep))
+	if len(l.buckets) != 1 {
+		t.Errorf("expected the refilled bucket of b to be forgotten, got: %v", l.buckets)
+	}
+	if newLimiter(0, 10) != nil {
+		t.Error("rate 0 expected to be no limit")
+	}
+}
+
+func TestRateLimitedRoute(t *testing.T) {
+	viper.Set("limits.routes.listSeeks.rate", 0.5)
+	viper.Set("limits.routes.listSeeks.burst", 2)
+	defer viper.Set("limits.routes.listSeeks", nil)
+	mux := newRouter()
+	list := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/lobby/seeks", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	for range 2 {
+		if rec := list("192.0.2.1:1000"); rec.Code != http.StatusOK {
+			t.Fatalf("GET seeks within the burst expected 200, got: %d", rec.Code)
+		}
+	}
+	rec := list("192.0.2.1:2000")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("GET seeks above the burst expected 429 retrying after 2s, got: %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var resp errorResponse
+	if json.Unmarshal(rec.Body.Bytes(), &resp); resp.Code != "rate_limited" {
+		t.Errorf("expected rate_limited, got: %v", rec.Body.String())
+	}
+	if rec := list("192.0.2.2:1000"); rec.Code != http.StatusOK {
+		t.Errorf("GET seeks of another address expected 200, got: %d", rec.Code)
+	}
+	if rec := list("192.0.2.2:1000"); rec.Header().Get("Retry-After") != "" {
+		t.Errorf("a served request expected no Retry-After, got: %q", rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestMoveBodyLimit(t *testing.T) {
+	mux := newRouter()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/startGame", strings.NewReader(`{}`)))
+	var started gameResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil {
+		t.Fatalf("POST /v1/startGame expected a game, got: %v", rec.Body.String())
+	}
+	body := `{"move":"e4","padding":"` + strings.Repeat("x", maxMoveBody) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/v2/games/"+strconv.Itoa(started.GameId)+"/move", strings.NewReader(body))
+	req.Header.Set(seatTokenHeader, started.SeatToken)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "body_too_large") {
+		t.Errorf("oversized move expected 413, got: %d %v", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOpenGamesLimit(t *testing.T) {
+	viper.Set("limits.openGames", 2)
+	viper.Set("limits.openGameIdle", time.Hour)
+	defer viper.Set("limits.openGames", nil)
+	defer viper.Set("limits.openGameIdle", nil)
+	mux := newRouter()
+	start := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/startGame", strings.NewReader(`{}`))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	var first gameResponse
+	json.Unmarshal(start("198.51.100.1:1000").Body.Bytes(), &first)
+	start("198.51.100.1:1000")
+	rec := start("198.51.100.1:2000")
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "too_many_open_games") {
+		t.Errorf("third open game expected 429, got: %d %v", rec.Code, rec.Body.String())
+	}
+	if rec := start("198.51.100.2:1000"); rec.Code != http.StatusOK {
+		t.Errorf("game of another client expected 200, got: %d", rec.Code)
+	}
+
+	var second gameResponse
+	json.Unmarshal(start("198.51.100.2:1000").Body.Bytes(), &second)
+	finished := getLiveGame(second.GameId)
+	finished.mu.Lock()
+	finished.g.Finish(game.Draw)
+	finished.publishResult()
+	finished.mu.Unlock()
+	if rec := start("198.51.100.2:1000"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("a game just over expected to still count, got: %d", rec.Code)
+	}
+
+	lg := getLiveGame(first.GameId)
+	lg.mu.Lock()
+	lg.lastActive = time.Now().Add(-2 * time.Hour)
+	lg.mu.Unlock()
+	// getLiveGame brings back the stored record of an evicted game
+	live := func(id int) bool {
+		liveGamesMu.Lock()
+		defer liveGamesMu.Unlock()
+		return liveGames[id] != nil
+	}
+	evictGames(time.Now())
+	if !live(first.GameId) {
+		t.Error("an idle game without a clock expected to be kept")
+	}
+	if !live(second.GameId) {
+		t.Error("a game just over expected to be kept")
+	}
+	if rec := start("198.51.100.1:1000"); rec.Code != http.StatusOK {
+		t.Errorf("an idle game expected to no longer count, got: %d", rec.Code)
+	}
+	evictGames(time.Now().Add(2 * defaultFinishedGameTtl))
+	if live(second.GameId) || storage.GetState(second.GameId) != nil {
+		t.Error("a game over for long expected to be evicted")
+	}
+	if storage.GetGameById(second.GameId) == nil {
+		t.Error("an evicted game expected to stay stored")
+	}
+	if rec := start("198.51.100.2:1000"); rec.Code != http.StatusOK {
+		t.Errorf("an evicted game over expected to no longer count, got: %d", rec.Code)
+	}
+
+	clocked := newLiveGame(context.Background(), game.StartGame(), true, nil, newClock(time.Minute, 0))
+	started := newLiveGame(context.Background(), game.StartGame(), true, nil, newClock(2*time.Hour, 0))
+	for _, lg := range []*liveGame{clocked, started} {
+		lg.mu.Lock()
+		if lg == started {
+			m, _ := game.ParseMove("e2e4")
+			lg.play(context.Background(), m)
+		}
+		lg.lastActive = time.Now().Add(-2 * time.Hour)
+		lg.mu.Unlock()
+	}
+	evictGames(time.Now())
+	if live(clocked.id) {
+		t.Error("a game whose clock nobody started expected to be evicted")
+	}
+	if !live(started.id) {
+		t.Error("a game with a running clock expected to be left to the clock")
+	}
+}
+
+func TestOpenGamesLimitOfMatchesAndRematches(t *testing.T) {
+	viper.Set("limits.openGames", 1)
+	defer viper.Set("limits.openGames", nil)
+	defaultLobby = newLobby()
+	mux := newRouter()
+	do := func(method, path, body, remoteAddr string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	var a, b seekResponse
+	json.Unmarshal(do(http.MethodPost, "/v1/lobby/seeks", `{}`, "198.51.100.3:1000", nil).Body.Bytes(), &a)
+	json.Unmarshal(do(http.MethodPost, "/v1/lobby/seeks", `{}`, "198.51.100.4:1000", nil).Body.Bytes(), &b)
+	defaultLobby.match()
+	var matched seekResponse
+	json.Unmarshal(do(http.MethodGet, "/v1/lobby/seeks/"+strconv.Itoa(a.SeekId), "", "198.51.100.3:1000", map[string]string{seekTokenHeader: a.SeekToken}).Body.Bytes(), &matched)
+	if matched.Seat == nil {
+		t.Fatalf("match() expected a game, got: %+v", matched)
+	}
+	for _, remoteAddr := range []string{"198.51.100.3:1000", "198.51.100.4:1000"} {
+		if rec := do(http.MethodPost, "/v1/lobby/seeks", `{}`, remoteAddr, nil); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("seek of %v expected the matched game to count, got: %d", remoteAddr, rec.Code)
+		}
+	}
+
+	viper.Set("limits.openGames", 2)
+	var started gameResponse
+	json.Unmarshal(do(http.MethodPost, "/v1/startGame", `{"opponent":"computer","level":1}`, "198.51.100.3:1000", nil).Body.Bytes(), &started)
+	lg := getLiveGame(started.GameId)
+	lg.mu.Lock()
+	lg.g.Finish(game.BlackWon)
+	lg.publishResult()
+	lg.mu.Unlock()
+	rec := do(http.MethodPost, "/v1/games/"+strconv.Itoa(started.GameId)+"/rematch", `{"action":"offer"}`, "198.51.100.3:1000",
+		map[string]string{seatTokenHeader: started.SeatToken})
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "too_many_open_games") {
+		t.Errorf("rematch above the limit expected 429, got: %d %v", rec.Code, rec.Body.String())
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.9:4000"
+	req.Header.Add("X-Forwarded-For", "10.0.0.1, 203.0.113.7")
+	if ip := clientIP(req); ip != "203.0.113.9" {
+		t.Errorf("X-Forwarded-For expected to be ignored without a proxy, got: %v", ip)
+	}
+	viper.Set("limits.trustProxy", true)
+	defer viper.Set("limits.trustProxy", nil)
+	if ip := clientIP(req); ip != "203.0.113.7" {
+		t.Errorf("expected the address the proxy appended, got: %v", ip)
+	}
+}
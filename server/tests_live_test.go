@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"lets-go-chess/game"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketUpdates(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	_, blackToken, _ := lg.claim(lg.black.invite, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{id}/ws", liveUpdates)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/games/" + strconv.Itoa(lg.id) + "/ws"
+
+	white := dial(t, url+"?token="+lg.white.token)
+	black := dial(t, url+"?token="+blackToken)
+	if e := receive(t, white); e.Type != joinEvent || e.By != "black" {
+		t.Errorf("expected black to have joined, got: %+v", e)
+	}
+	receive(t, black)
+	send(t, white, clientMessage{Type: "move", Move: "e2e4"})
+	var seen int
+	for _, conn := range []*websocket.Conn{white, black} {
+		e := receive(t, conn)
+		if e.Type != moveEvent || e.Move != "e2e4" || e.Ply != 1 || e.IsWhite {
+			t.Errorf("expected move e2e4 at ply 1 with black to move, got: %+v", e)
+		}
+		seen = e.Id
+	}
+	send(t, black, clientMessage{Type: "move", Move: "d2d4"})
+	if e := receive(t, black); e.Type != errorEvent || e.Error.Code != "wrong_color" {
+		t.Errorf("expected wrong_color error, got: %+v", e)
+	}
+	send(t, white, clientMessage{Type: "move", Move: "d2d4"})
+	if e := receive(t, white); e.Type != errorEvent || e.Error.Code != "not_your_turn" {
+		t.Errorf("expected not_your_turn error, got: %+v", e)
+	}
+	send(t, black, clientMessage{Type: "move", Move: "e7e5"})
+	receive(t, white)
+	receive(t, black)
+
+	resumed := dial(t, url+"?lastEventId="+strconv.Itoa(seen))
+	if e := receive(t, resumed); e.Type != moveEvent || e.Move != "e7e5" || e.Ply != 2 {
+		t.Errorf("expected the resumed stream to start with e7e5, got: %+v", e)
+	}
+	byPly := dial(t, url+"?since=1")
+	if e := receive(t, byPly); e.Type != moveEvent || e.Move != "e7e5" || e.Ply != 2 {
+		t.Errorf("expected the stream resumed by ply to start with e7e5, got: %+v", e)
+	}
+	byPly.Close()
+	send(t, resumed, clientMessage{Type: "move", Move: "g1f3"})
+	if e := receive(t, resumed); e.Type != errorEvent || e.Error.Code != "seat_token_required" {
+		t.Errorf("expected seat_token_required error for a watcher, got: %+v", e)
+	}
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"?token=guess", nil); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a wrong token to be refused, got: %v", err)
+	}
+
+	send(t, white, clientMessage{Type: "offerDraw"})
+	if e := receive(t, black); e.Type != drawOfferEvent || e.By != "white" {
+		t.Errorf("expected a draw offer by white, got: %+v", e)
+	}
+	send(t, white, clientMessage{Type: "acceptDraw"})
+	if e := receiveType(t, white, errorEvent); e.Error.Code != "no_draw_offer" {
+		t.Errorf("expected no_draw_offer error, got: %+v", e.Error)
+	}
+	send(t, black, clientMessage{Type: "acceptDraw"})
+	if e := receive(t, resumed); e.Type != drawOfferEvent {
+		t.Errorf("expected the draw offer, got: %+v", e)
+	}
+	if e := receive(t, resumed); e.Type != resultEvent || e.Result != "draw" {
+		t.Errorf("expected a draw, got: %+v", e)
+	}
+}
+
+func TestServerSentEvents(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	lg.mu.Lock()
+	for _, notation := range []string{"e2e4", "e7e5"} {
+		m, _ := game.ParseMove(notation)
+		lg.play(context.Background(), m)
+	}
+	lg.mu.Unlock()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{id}/events", gameEvents)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/games/"+strconv.Itoa(lg.id)+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events error: %v", err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("expected an event stream, got: %v", contentType)
+	}
+	stream := bufio.NewReader(resp.Body)
+	if e := readEvent(t, stream); e.Id != 2 || e.Move != "e7e5" {
+		t.Errorf("expected the stream to resume with e7e5, got: %+v", e)
+	}
+	lg.mu.Lock()
+	m, _ := game.ParseMove("g1f3")
+	lg.play(context.Background(), m)
+	lg.mu.Unlock()
+	if e := readEvent(t, stream); e.Id != 3 || e.Type != moveEvent || e.Move != "g1f3" {
+		t.Errorf("expected g1f3 as event 3, got: %+v", e)
+	}
+}
+
+func readEvent(t *testing.T, stream *bufio.Reader) gameEvent {
+	var id, eventType string
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream error: %v", err)
+		}
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimSpace(line[4:])
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimSpace(line[7:])
+		case strings.HasPrefix(line, "data: "):
+			var e gameEvent
+			if err = json.Unmarshal([]byte(line[6:]), &e); err != nil {
+				t.Fatalf("unmarshalling %v error: %v", line, err)
+			}
+			if strconv.Itoa(e.Id) != id || e.Type != eventType {
+				t.Errorf("expected id %v and event %v to match the data, got: %+v", id, eventType, e)
+			}
+			return e
+		}
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Now()
+	c := newClock(time.Minute, 2*time.Second)
+	if c.flagged(start.Add(time.Hour)) {
+		t.Errorf("flagged() expected the clock to wait for the first move")
+	}
+	c.press(start)
+	c.press(start.Add(10 * time.Second))
+	white, black := c.remaining(start.Add(15 * time.Second))
+	if white != 55*time.Second || black != 52*time.Second {
+		t.Errorf("remaining() expected 55s and 52s, got: %v, %v", white, black)
+	}
+	if !c.flagged(start.Add(2 * time.Minute)) {
+		t.Errorf("flagged() expected white out of time")
+	}
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial(%v) error: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, msg clientMessage) {
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("WriteJSON(%+v) error: %v", msg, err)
+	}
+}
+
+func receive(t *testing.T, conn *websocket.Conn) gameEvent {
+	var e gameEvent
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&e); err != nil {
+		t.Fatalf("ReadJSON() error: %v", err)
+	}
+	return e
+}
+
+// receiveType skips events until one of the given type arrives, replies to
+// the client and broadcast events are not ordered against each other.
+func receiveType(t *testing.T, conn *websocket.Conn, eventType string) gameEvent {
+	for {
+		if e := receive(t, conn); e.Type == eventType {
+			return e
+		}
+	}
+}
+
+func TestJoinGame(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), false, nil, nil)
+	invite := lg.inviteUrl()
+	if invite == "" {
+		t.Fatalf("inviteUrl() expected a link for the free seat")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /games/{id}/join", joinGame)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, invite, nil))
+	var resp seatResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Color != "white" || resp.SeatToken == "" {
+		t.Fatalf("join expected the white seat, got: %d %v", rec.Code, rec.Body.String())
+	}
+	if isWhite, err := lg.authorize(resp.SeatToken); err != nil || !isWhite {
+		t.Errorf("authorize() expected the token of the white seat, got: %v, %v", isWhite, err)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, invite, nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("join with a used invite expected 403, got: %d", rec.Code)
+	}
+	if lg.inviteUrl() != "" {
+		t.Errorf("inviteUrl() expected no link once both seats are taken")
+	}
+}
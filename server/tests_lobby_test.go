@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/accounts"
+	"lets-go-chess/ratings"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeekCompatible(t *testing.T) {
+	blitz := &timeControlRequest{Initial: 300, Increment: 2}
+	base := seekRequest{TimeControl: blitz, Variant: standardVariant, Color: "random", Rating: 1500}
+	tests := []struct {
+		name    string
+		change  func(r *seekRequest)
+		eResult bool
+	}{
+		{name: "same", change: func(r *seekRequest) {}, eResult: true},
+		{name: "other time control", change: func(r *seekRequest) { r.TimeControl = &timeControlRequest{Initial: 60} }, eResult: false},
+		{name: "no clock", change: func(r *seekRequest) { r.TimeControl = nil }, eResult: false},
+		{name: "rated", change: func(r *seekRequest) { r.Rated = true }, eResult: false},
+		{name: "out of range", change: func(r *seekRequest) { r.MinRating = 1600 }, eResult: false},
+		{name: "in range", change: func(r *seekRequest) { r.MinRating, r.MaxRating = 1400, 1600 }, eResult: true},
+	}
+	for _, test := range tests {
+		other := base
+		test.change(&other)
+		a, b := &seek{seekRequest: base, client: "ip:192.0.2.1"}, &seek{seekRequest: other, client: "ip:192.0.2.2"}
+		if a.compatible(b) != test.eResult || b.compatible(a) != test.eResult {
+			t.Errorf("%v: compatible() expected %v", test.name, test.eResult)
+		}
+	}
+	white := &seek{seekRequest: base, client: "ip:192.0.2.1"}
+	white.Color = "white"
+	if !white.compatible(&seek{seekRequest: base, client: "ip:192.0.2.2"}) || white.compatible(&seek{seekRequest: white.seekRequest, client: "ip:192.0.2.2"}) {
+		t.Errorf("compatible() expected white to meet random but not white")
+	}
+	if (&seek{seekRequest: base, client: "ip:192.0.2.1"}).compatible(&seek{seekRequest: base, client: "ip:192.0.2.1"}) {
+		t.Errorf("compatible() expected the seeks of a client not to meet")
+	}
+}
+
+func TestLobbyMatchesSeeks(t *testing.T) {
+	defaultLobby = newLobby()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /lobby/seeks", listSeeks)
+	mux.HandleFunc("POST /lobby/seeks", createSeek)
+	mux.HandleFunc("GET /lobby/seeks/{id}", getSeek)
+	mux.HandleFunc("DELETE /lobby/seeks/{id}", cancelSeek)
+	seekers := 0
+	post := func(body string) seekResponse {
+		seekers++
+		req := httptest.NewRequest(http.MethodPost, "/lobby/seeks", strings.NewReader(body))
+		req.RemoteAddr = "192.0.2." + strconv.Itoa(seekers) + ":1000"
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var resp seekResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusCreated ||
+			rec.Result().Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("POST seek %v expected 201 with JSON, got: %d %v %v", body, rec.Code, rec.Result().Header, rec.Body.String())
+		}
+		return resp
+	}
+	get := func(s seekResponse) seekResponse {
+		req := httptest.NewRequest(http.MethodGet, "/lobby/seeks/"+strconv.Itoa(s.SeekId), nil)
+		req.Header.Set(seekTokenHeader, s.SeekToken)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var resp seekResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/lobby/seeks", strings.NewReader(`{"variant":"chess960"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST seek with an unknown variant expected 400, got: %d", rec.Code)
+	}
+
+	white := post(`{"color":"white","timeControl":{"initial":180,"increment":2}}`)
+	blitz := post(`{"timeControl":{"initial":300}}`)
+	black := post(`{"timeControl":{"initial":180,"increment":2}}`)
+	cancelled := post(`{}`)
+
+	req := httptest.NewRequest(http.MethodDelete, "/lobby/seeks/"+strconv.Itoa(cancelled.SeekId), nil)
+	req.Header.Set(seekTokenHeader, "guess")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("DELETE seek with a wrong token expected 403, got: %d", rec.Code)
+	}
+	req.Header.Set(seekTokenHeader, cancelled.SeekToken)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE seek expected 204, got: %d", rec.Code)
+	}
+
+	defaultLobby.match()
+	whiteSeat, blackSeat := get(white).Seat, get(black).Seat
+	if whiteSeat == nil || blackSeat == nil || whiteSeat.GameId != blackSeat.GameId {
+		t.Fatalf("match() expected both 3+2 seeks in one game, got: %+v, %+v", whiteSeat, blackSeat)
+	}
+	if whiteSeat.Color != "white" || blackSeat.Color != "black" {
+		t.Errorf("match() expected the colour preference to be kept, got: %v, %v", whiteSeat.Color, blackSeat.Color)
+	}
+	lg := getLiveGame(whiteSeat.GameId)
+	if isWhite, err := lg.authorize(blackSeat.SeatToken); err != nil || isWhite {
+		t.Errorf("authorize() expected the black seat, got: %v, %v", isWhite, err)
+	}
+	if lg.clock == nil || lg.clock.increment.Seconds() != 2 {
+		t.Errorf("match() expected a 3+2 clock")
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lobby/seeks", nil))
+	var open []seekResponse
+	json.Unmarshal(rec.Body.Bytes(), &open)
+	if len(open) != 1 || open[0].SeekId != blitz.SeekId || open[0].SeekToken != "" || open[0].Seat != nil {
+		t.Errorf("GET seeks expected only the 5+0 seek without secrets, got: %+v", open)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/lobby/seeks/"+strconv.Itoa(white.SeekId), nil)
+	req.Header.Set(seekTokenHeader, white.SeekToken)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET seek after its seat was read expected 404, got: %d", rec.Code)
+	}
+
+	defaultLobby.mu.Lock()
+	defaultLobby.seeks[blitz.SeekId].created = time.Now().Add(-2 * defaultSeekTtl)
+	defaultLobby.mu.Unlock()
+	defaultLobby.match()
+	if s := get(blitz); s.SeekId != 0 {
+		t.Errorf("match() expected the stale seek to expire, got: %+v", s)
+	}
+}
+
+func TestLobbyKeepsClientsApart(t *testing.T) {
+	defaultLobby = newLobby()
+	post := func(remoteAddr string) seekResponse {
+		req := httptest.NewRequest(http.MethodPost, "/lobby/seeks", strings.NewReader(`{}`))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		createSeek(rec, req)
+		var resp seekResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+	first, second := post("198.51.100.7:1000"), post("198.51.100.7:2000")
+	defaultLobby.match()
+	defaultLobby.mu.Lock()
+	if s := defaultLobby.seeks[first.SeekId]; s.seat != nil || defaultLobby.seeks[second.SeekId].seat != nil {
+		t.Errorf("match() expected the seeks of one client not to meet, got: %+v", s.seat)
+	}
+	defaultLobby.mu.Unlock()
+	other := post("198.51.100.8:1000")
+	defaultLobby.match()
+	defaultLobby.mu.Lock()
+	defer defaultLobby.mu.Unlock()
+	if defaultLobby.seeks[first.SeekId].seat == nil || defaultLobby.seeks[other.SeekId].seat == nil || defaultLobby.seeks[second.SeekId].seat != nil {
+		t.Error("match() expected the oldest seek to meet the other client")
+	}
+}
+
+func TestSeekRatingOfAccount(t *testing.T) {
+	defaultLobby = newLobby()
+	u, _ := accounts.Register("seeker", "correct horse")
+	req := httptest.NewRequest(http.MethodPost, "/lobby/seeks", strings.NewReader(`{"rating":2800}`))
+	req = req.WithContext(context.WithValue(req.Context(), userContextKey{}, u))
+	rec := httptest.NewRecorder()
+	createSeek(rec, req)
+	var resp seekResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusCreated || resp.Rating != ratings.DefaultRating {
+		t.Errorf("POST seek of a user expected the account's rating, got: %d %v", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"lets-go-chess/logging"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logBuffer collects the log records of a test, games of other tests may
+// still log from their goroutines.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records returns the records logged for the request id.
+func (b *logBuffer) records(requestId string) []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var record map[string]any
+		if json.Unmarshal([]byte(line), &record) == nil && record["request_id"] == requestId {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestRequestLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	logs := &logBuffer{}
+	if err := logging.Setup(logs, "debug", "json"); err != nil {
+		t.Fatalf("Setup expected to succeed, got: %v", err)
+	}
+	handler := requestIdMiddleware(recoverMiddleware(newRouter()))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/startGame", strings.NewReader(`{}`)))
+	startId := rec.Header().Get(requestIdHeader)
+	var started gameResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil || startId == "" {
+		t.Fatalf("POST /v1/startGame expected a game with a request id, got: %v", rec.Body.String())
+	}
+	messages := map[string]map[string]any{}
+	for _, record := range logs.records(startId) {
+		messages[record["msg"].(string)] = record
+	}
+	if stored := messages["Game stored"]; stored == nil || stored["game_id"] != float64(started.GameId) {
+		t.Errorf("expected the storage to log with the request id, got: %v", messages)
+	}
+	served := messages["Request served"]
+	if served == nil || served["route"] != "/v1/startGame" || served["method"] != "POST" || served["status"] != float64(200) ||
+		served["game_id"] != float64(started.GameId) || served["latency"] == nil {
+		t.Errorf("expected the request to be logged with its game, got: %v", served)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/games/"+strconv.Itoa(started.GameId)+"/move", strings.NewReader(`{"move":"e5"}`))
+	req.Header.Set(seatTokenHeader, started.SeatToken)
+	req.Header.Set(requestIdHeader, "proxy-1")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if id := rec.Header().Get(requestIdHeader); id != "proxy-1" {
+		t.Errorf("expected the request id of the proxy to be kept, got: %v", id)
+	}
+	records := logs.records("proxy-1")
+	if len(records) != 1 || records[0]["status"] != float64(http.StatusUnprocessableEntity) ||
+		records[0]["route"] != "/v2/games/{id}/move" || records[0]["game_id"] != float64(started.GameId) || records[0]["error"] == nil {
+		t.Errorf("expected the refused move to be logged with its error, got: %v", records)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(requestIdHeader, "not a\nvalid id")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if id := rec.Header().Get(requestIdHeader); id == "" || strings.ContainsAny(id, " \n") {
+		t.Errorf("expected an invalid request id to be replaced, got: %q", id)
+	}
+}
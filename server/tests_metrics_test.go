@@ -0,0 +1,41 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	mux := newRouter()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/startGame", strings.NewReader(`{}`)))
+	var started gameResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil {
+		t.Fatalf("POST /v1/startGame expected a game, got: %v", rec.Body.String())
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v2/games/"+strconv.Itoa(started.GameId)+"/move", strings.NewReader(`{"move":"e4"}`))
+	req.Header.Set(seatTokenHeader, started.SeatToken)
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/games/0", nil))
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, expected := range []string{
+		`chess_http_requests_total{route="/v1/startGame",method="POST",status="200"}`,
+		`chess_http_requests_total{route="/v1/games/{id}",method="GET",status="404"}`,
+		`chess_http_request_duration_seconds_count{route="/v2/games/{id}/move",method="POST"}`,
+		`chess_moves_total{player="human"}`,
+		`chess_move_validation_seconds_count `,
+		"\nchess_active_games ",
+		"\nchess_engine_nodes_total ",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("metrics expected to contain %v", expected)
+		}
+	}
+}
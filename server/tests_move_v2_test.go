@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/game"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMoveV2(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	_, blackToken, _ := lg.claim(lg.black.invite, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v2/games/{id}/move", moveV2)
+	tests := []struct {
+		token  string
+		move   string
+		status int
+		eSan   string
+		eUci   string
+		eCode  string
+	}{
+		{token: lg.white.token, move: "e4", status: http.StatusOK, eSan: "e4", eUci: "e2e4"},
+		{token: lg.white.token, move: "d4", status: http.StatusConflict, eCode: "not_your_turn"},
+		{token: blackToken, move: "d7d5", status: http.StatusOK, eSan: "d5", eUci: "d7d5"},
+		{token: lg.white.token, move: "exd5", status: http.StatusOK, eSan: "exd5", eUci: "e4d5"},
+		{token: blackToken, move: "Zz9", status: http.StatusBadRequest, eCode: "invalid_notation"},
+		{token: blackToken, move: "Ke7", status: http.StatusUnprocessableEntity, eCode: "move_rules_violation"},
+		{token: blackToken, move: "Qxd5", status: http.StatusOK, eSan: "Qxd5", eUci: "d8d5"},
+		{token: lg.white.token, move: "Nc3", status: http.StatusOK, eSan: "Nc3", eUci: "b1c3"},
+		{token: blackToken, move: "Qe5+", status: http.StatusOK, eSan: "Qe5+", eUci: "d5e5"},
+		{token: lg.white.token, move: "Ne2", status: http.StatusUnprocessableEntity, eCode: "ambiguous_move"},
+		{token: lg.white.token, move: "Nge2", status: http.StatusOK, eSan: "Nge2", eUci: "g1e2"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v2/games/"+strconv.Itoa(lg.id)+"/move", strings.NewReader(`{"move":"`+test.move+`"}`))
+		req.Header.Set(seatTokenHeader, test.token)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var resp moveV2Response
+		var errResp errorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		json.Unmarshal(rec.Body.Bytes(), &errResp)
+		if rec.Code != test.status || resp.San != test.eSan || resp.Uci != test.eUci || test.eCode != "" && errResp.Code != test.eCode {
+			t.Errorf("move %v expected %d %v %v %v, got: %d %v", test.move, test.status, test.eSan, test.eUci, test.eCode, rec.Code, rec.Body.String())
+		}
+	}
+	backlog, events := lg.events.subscribe(func(gameEvent) bool { return true }, func(gameEvent) bool { return true })
+	lg.events.unsubscribe(events)
+	if e := backlog[len(backlog)-1]; e.Type != moveEvent || e.San != "Nge2" || e.Move != "g1e2" {
+		t.Errorf("expected the move event in both notations, got: %+v", e)
+	}
+}
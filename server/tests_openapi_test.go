@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDescribesRoutes(t *testing.T) {
+	mux := newRouter()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc openAPIDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json expected the document, got: %d %v", rec.Code, err)
+	}
+	if doc.OpenAPI != openAPIVersion {
+		t.Errorf("openapi expected %v, got: %v", openAPIVersion, doc.OpenAPI)
+	}
+
+	documented := make(map[string]bool)
+	for _, rt := range apiRoutes {
+		op := doc.Paths[rt.versionedPath()][strings.ToLower(rt.method)]
+		if op == nil {
+			t.Errorf("%v %v expected to be documented", rt.method, rt.versionedPath())
+			continue
+		}
+		if op.Responses[strconv.Itoa(rt.successStatus())] == nil {
+			t.Errorf("%v %v expected the %d response", rt.method, rt.versionedPath(), rt.successStatus())
+		}
+		for _, resp := range op.Responses {
+			for _, code := range strings.Split(resp.Description, ", ") {
+				documented[code] = true
+			}
+		}
+		for _, err := range rt.socketErrors {
+			if !strings.Contains(op.Description, apiErrors[err].code) {
+				t.Errorf("%v %v expected to describe %v", rt.method, rt.versionedPath(), apiErrors[err].code)
+			}
+			documented[apiErrors[err].code] = true
+		}
+	}
+	codes := doc.Components.Schemas["ErrorResponse"].Properties["code"].Enum
+	if len(codes) != len(apiErrors) {
+		t.Errorf("error codes expected %d, got: %v", len(apiErrors), codes)
+	}
+	for _, e := range apiErrors {
+		if !documented[e.code] {
+			t.Errorf("error code %v expected on a route", e.code)
+		}
+	}
+
+	// every reference resolves
+	body := rec.Body.String()
+	for _, part := range strings.Split(body, `"$ref":"#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(part, `"`)
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("schema %v expected in the components", name)
+		}
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	defaultLobby = newLobby()
+	mux := newRouter()
+	for _, path := range []string{"/v1/lobby/seeks", "/lobby/seeks"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %v expected 200, got: %d", path, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/startGame", nil))
+	var started gameResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("POST /v1/startGame without a body expected a game, got: %d %v", rec.Code, rec.Body.String())
+	}
+	id := strconv.Itoa(started.GameId)
+	for _, path := range []string{"/v1/games/" + id, "/games/" + id, "/v1/games/" + id + "/moves"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %v expected 200, got: %d", path, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/move", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /v1/move expected 405, got: %d", rec.Code)
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	mux := newRouter()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/startGame", strings.NewReader(`{}`)))
+	var started gameResponse
+	json.Unmarshal(rec.Body.Bytes(), &started)
+	id := strconv.Itoa(started.GameId)
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		eStatus int
+		eCode   string
+		eField  string
+	}{
+		{name: "unknown field", path: "/v1/move", body: `{"gameId":1,"fromX":5,"fromY":2,"toX":5,"toY":4,"promote":"q"}`, eStatus: http.StatusBadRequest, eCode: "invalid_request", eField: "promote"},
+		{name: "wrong type", path: "/v1/move", body: `{"gameId":"1","fromX":5,"fromY":2,"toX":5,"toY":4}`, eStatus: http.StatusBadRequest, eCode: "invalid_request", eField: "gameId"},
+		{name: "fraction", path: "/v1/move", body: `{"gameId":1,"fromX":5.5,"fromY":2,"toX":5,"toY":4}`, eStatus: http.StatusBadRequest, eCode: "invalid_request", eField: "fromX"},
+		{name: "missing field", path: "/v1/move", body: `{"gameId":1,"fromX":5,"fromY":2,"toX":5}`, eStatus: http.StatusBadRequest, eCode: "invalid_request", eField: "toY"},
+		{name: "no body", path: "/v1/move", body: ``, eStatus: http.StatusBadRequest, eCode: "invalid_request"},
+		{name: "trailing data", path: "/v1/move", body: `{"gameId":1,"fromX":5,"fromY":2,"toX":5,"toY":4}{}`, eStatus: http.StatusBadRequest, eCode: "invalid_request"},
+		{name: "not in enum", path: "/v1/startGame", body: `{"color":"green"}`, eStatus: http.StatusBadRequest, eCode: "invalid_request", eField: "color"},
+		{name: "nested", path: "/v1/startGame", body: `{"timeControl":{"initial":0}}`, eStatus: http.StatusBadRequest, eCode: "invalid_request", eField: "timeControl.initial"},
+		{name: "null", path: "/v1/startGame", body: `{"timeControl":null,"color":null}`, eStatus: http.StatusBadRequest, eCode: "invalid_request", eField: "color"},
+		{name: "action", path: "/v1/games/" + id + "/takeback", body: `{"action":"undo"}`, eStatus: http.StatusBadRequest, eCode: "invalid_request", eField: "action"},
+		{name: "legacy path", path: "/lobby/seeks", body: `{"variant":"chess960"}`, eStatus: http.StatusBadRequest, eCode: "invalid_request"},
+		{name: "legacy unknown field", path: "/startGame", body: `{"color":"","theme":"dark"}`, eStatus: http.StatusOK},
+		{name: "legacy empty color", path: "/lobby/seeks", body: `{"color":""}`, eStatus: http.StatusCreated},
+		{name: "versioned empty color", path: "/v1/lobby/seeks", body: `{"color":""}`, eStatus: http.StatusBadRequest, eCode: "invalid_request", eField: "color"},
+		{name: "domain error", path: "/v1/move", body: `{"gameId":-1,"fromX":5,"fromY":2,"toX":5,"toY":4}`, eStatus: http.StatusNotFound, eCode: "unknown_game"},
+		{name: "v2", path: "/v2/games/" + id + "/move", body: `{"move":"e2e4","uci":true}`, eStatus: http.StatusBadRequest, eCode: "invalid_request", eField: "uci"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body))
+		req.Header.Set(seatTokenHeader, started.SeatToken)
+		mux.ServeHTTP(rec, req)
+		var resp struct {
+			Code    string       `json:"code"`
+			Details []fieldError `json:"details"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != test.eStatus || resp.Code != test.eCode {
+			t.Errorf("%v: expected %d %v, got: %d %v", test.name, test.eStatus, test.eCode, rec.Code, rec.Body.String())
+			continue
+		}
+		if test.eField != "" && (len(resp.Details) == 0 || resp.Details[0].Field != test.eField) {
+			t.Errorf("%v: expected a detail of %v, got: %v", test.name, test.eField, rec.Body.String())
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/move", strings.NewReader(`{"gameId":`+id+`,"fromX":5,"fromY":2,"toX":5,"toY":9}`))
+	req.Header.Set(seatTokenHeader, started.SeatToken)
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "to_out_of_bounds") {
+		t.Errorf("a valid request with an illegal move expected to_out_of_bounds, got: %d %v", rec.Code, rec.Body.String())
+	}
+}
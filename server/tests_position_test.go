@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/game"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestPositionResponse(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	lg.mu.Lock()
+	for _, notation := range []string{"e2e4", "d7d5", "e4d5", "d8d5", "b1c3", "d5e5"} {
+		m, _ := game.ParseMove(notation)
+		lg.play(context.Background(), m)
+	}
+	lg.mu.Unlock()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{id}", gameState)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/games/"+strconv.Itoa(lg.id), nil))
+	var resp gameStateResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("GET game error: %v", err)
+	}
+	expected := positionResponse{
+		Fen:         "rnb1kbnr/ppp1pppp/8/4q3/8/2N5/PPPP1PPP/R1BQKBNR w KQkq - 2 4",
+		LastMove:    &lastMoveResponse{From: "d5", To: "e5", San: "Qe5+", Uci: "d5e5"},
+		CheckSquare: "e1",
+		Captured:    capturedResponse{White: []string{"P"}, Black: []string{"p"}},
+		Ply:         6,
+		MoveNumber:  4,
+		Result:      "ongoing",
+	}
+	if !reflect.DeepEqual(resp.positionResponse, expected) {
+		t.Errorf("GET game expected position %+v, got: %+v", expected, resp.positionResponse)
+	}
+	if resp.Situation != game.Check || len(resp.Moves) != 6 {
+		t.Errorf("GET game expected the other fields next to the position, got: %v", rec.Body.String())
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/accounts"
+	"lets-go-chess/game"
+	"lets-go-chess/ratings"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestRatedGameUpdatesRatings(t *testing.T) {
+	winner, _ := accounts.Register("fool_mate", "correct horse")
+	loser, _ := accounts.Register("fool", "correct horse")
+	blitz := &timeControlRequest{Initial: 300}
+	lg := newLiveGame(context.Background(), game.StartGame(), false, nil, nil)
+	lg.mu.Lock()
+	lg.rated, lg.category = true, blitz.category()
+	lg.white.userId, lg.black.userId = loser.Id, winner.Id
+	for _, notation := range []string{"f2f3", "e7e5", "g2g4", "d8h4"} {
+		m, _ := game.ParseMove(notation)
+		lg.play(context.Background(), m)
+	}
+	lg.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}/ratings", userRatings)
+	mux.HandleFunc("GET /users/{id}/ratings/{category}", ratingHistory)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/"+strconv.Itoa(winner.Id)+"/ratings", nil))
+	var current map[ratings.Category]ratings.Rating
+	json.Unmarshal(rec.Body.Bytes(), &current)
+	if r := current[ratings.Blitz]; r.Rating <= ratings.DefaultRating || r.Games != 1 || current[ratings.Bullet].Games != 0 {
+		t.Errorf("GET ratings expected the won blitz game, got: %v", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/"+strconv.Itoa(loser.Id)+"/ratings/blitz", nil))
+	var history ratingHistoryResponse
+	json.Unmarshal(rec.Body.Bytes(), &history)
+	if len(history.History) != 1 || history.History[0].GameId != lg.id || history.Current.Rating >= ratings.DefaultRating {
+		t.Errorf("GET rating history expected the lost game, got: %v", rec.Body.String())
+	}
+
+	tests := []struct {
+		path  string
+		eCode string
+	}{
+		{path: "/users/999/ratings", eCode: "unknown_user"},
+		{path: "/users/" + strconv.Itoa(loser.Id) + "/ratings/armageddon", eCode: "unknown_category"},
+	}
+	for _, test := range tests {
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		var resp errorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != http.StatusNotFound || resp.Code != test.eCode {
+			t.Errorf("GET %v expected 404 %v, got: %d %v", test.path, test.eCode, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/game"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRematch(t *testing.T) {
+	blitz := &timeControlRequest{Initial: 180, Increment: 2}
+	c, _ := newClockFor(blitz)
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, c)
+	lg.timeControl = blitz
+	_, blackToken, _ := lg.claim(lg.black.invite, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{id}/ws", liveUpdates)
+	mux.HandleFunc("POST /games/{id}/rematch", rematch)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/games/" + strconv.Itoa(lg.id) + "/ws"
+	white := dial(t, url+"?token="+lg.white.token)
+	spectator := dial(t, url)
+	var contentType string
+	post := func(token, action string) (int, []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/games/"+strconv.Itoa(lg.id)+"/rematch", strings.NewReader(`{"action":"`+action+`"}`))
+		req.Header.Set(seatTokenHeader, token)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		contentType = rec.Result().Header.Get("Content-Type")
+		return rec.Code, rec.Body.Bytes()
+	}
+
+	if status, _ := post(lg.white.token, "offer"); status != http.StatusConflict {
+		t.Errorf("rematch offer of an ongoing game expected 409, got: %d", status)
+	}
+	lg.mu.Lock()
+	lg.g.Finish(game.BlackWon)
+	lg.publishResult()
+	lg.mu.Unlock()
+	if status, _ := post(lg.white.token, "offer"); status != http.StatusNoContent {
+		t.Fatalf("rematch offer expected 204, got: %d", status)
+	}
+	status, body := post(blackToken, "accept")
+	var seat seatResponse
+	json.Unmarshal(body, &seat)
+	if status != http.StatusCreated || contentType != "application/json" || seat.Color != "white" || seat.GameId == lg.id {
+		t.Fatalf("rematch accept expected the white seat of a new game as JSON, got: %d %v %s", status, contentType, body)
+	}
+
+	e := receiveType(t, white, rematchEvent)
+	if e.RematchId != seat.GameId || e.Seat == nil || e.Seat.Color != "black" || e.Seat.GameId != seat.GameId {
+		t.Errorf("expected white's seat in the rematch, got: %+v %+v", e, e.Seat)
+	}
+	if e := receiveType(t, spectator, rematchEvent); e.RematchId != seat.GameId || e.Seat != nil {
+		t.Errorf("expected the rematch without a seat for spectators, got: %+v", e)
+	}
+	if status, _ := post(lg.white.token, "offer"); status != http.StatusConflict {
+		t.Errorf("second rematch offer expected 409, got: %d", status)
+	}
+
+	next := getLiveGame(seat.GameId)
+	next.mu.Lock()
+	defer next.mu.Unlock()
+	if isWhite, err := next.authorize(e.Seat.SeatToken); err != nil || isWhite {
+		t.Errorf("expected the former white player to hold black, got: %v %v", isWhite, err)
+	}
+	if isWhite, err := next.authorize(seat.SeatToken); err != nil || !isWhite {
+		t.Errorf("expected the former black player to hold white, got: %v %v", isWhite, err)
+	}
+	if next.rematchOf != lg.id || next.timeControl != blitz || next.clock == nil || next.clock.white != 3*time.Minute || next.inviteUrl() != "" {
+		t.Errorf("expected a linked rematch with the same time control and both seats taken")
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"lets-go-chess/game"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestProbes(t *testing.T) {
+	l := newLifecycle()
+	defer currentLifecycle.Store(currentLifecycle.Swap(l))
+	mux := newRouter()
+	probe := func(path string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("readyz while starting expected 503, got: %d", code)
+	}
+	l.ready.Store(true)
+	if code := probe("/readyz"); code != http.StatusOK {
+		t.Errorf("readyz expected 200, got: %d", code)
+	}
+	l.stop()
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("readyz while shutting down expected 503, got: %d", code)
+	}
+	if code := probe("/healthz"); code != http.StatusOK {
+		t.Errorf("healthz expected 200, got: %d", code)
+	}
+}
+
+func TestShutdownDrainsStreams(t *testing.T) {
+	l := newLifecycle()
+	defer currentLifecycle.Store(currentLifecycle.Swap(l))
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	mux := newRouter()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	path := "/v1/games/" + strconv.Itoa(lg.id)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+path+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial expected to succeed, got: %v", err)
+	}
+	defer conn.Close()
+	resp, err := http.Get(srv.URL + path + "/events")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET events expected a stream, got: %v", err)
+	}
+	defer resp.Body.Close()
+	// the first ping tells that the stream is open
+	go bufio.NewReader(resp.Body).ReadString('\n')
+
+	storagePath := filepath.Join(t.TempDir(), "games.json")
+	done := make(chan struct{})
+	go func() {
+		shutdown(srv.Config, 5*time.Second, storagePath)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown expected to end the live streams")
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err = conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("WebSocket expected to be closed as going away, got: %v", err)
+	}
+	if _, err = io.ReadAll(resp.Body); err != nil {
+		t.Errorf("event stream expected to end, got: %v", err)
+	}
+	if _, err = os.Stat(storagePath); err != nil {
+		t.Errorf("games expected to be flushed, got: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"/events", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("a new stream while shutting down expected to be refused, got: %d", rec.Code)
+	}
+}
+
+func TestFlushAndRestoreGames(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, newClock(time.Minute, time.Second))
+	_, blackToken, _ := lg.claim(lg.black.invite, nil)
+	lg.mu.Lock()
+	lg.private = true
+	lg.white.userId = 1
+	lg.creators = []string{"ip:192.0.2.9", "user:1"}
+	for _, s := range []string{"e2e4", "e7e5", "g1f3"} {
+		m, _ := lg.g.ParseNotation(s)
+		if _, err := lg.play(context.Background(), m); err != nil {
+			t.Fatalf("play %v expected to succeed, got: %v", s, err)
+		}
+	}
+	lg.mu.Unlock()
+	resigned := newLiveGame(context.Background(), game.StartGame(), false, nil, nil)
+	resigned.mu.Lock()
+	resigned.g.Finish(game.WhiteWon)
+	resigned.mu.Unlock()
+
+	path := filepath.Join(t.TempDir(), "games.json")
+	if err := flushGames(path); err != nil {
+		t.Fatalf("flushGames expected to succeed, got: %v", err)
+	}
+	liveGamesMu.Lock()
+	liveGames = make(map[int]*liveGame)
+	liveGamesMu.Unlock()
+	if err := restoreGames(path); err != nil {
+		t.Fatalf("restoreGames expected to succeed, got: %v", err)
+	}
+
+	restored := getLiveGame(lg.id)
+	restored.mu.Lock()
+	if len(restored.g.Moves) != 3 || restored.g.IsWhiteMove || !restored.private {
+		t.Errorf("expected the game after g1f3 with black to move, got: %v", restored.g.Moves)
+	}
+	if isWhite, err := restored.authorize(blackToken); err != nil || isWhite {
+		t.Errorf("expected the black seat token to be kept, got: %v", err)
+	}
+	if restored.white.userId != 0 {
+		t.Errorf("expected the seat not to be kept for a user id which may be reused, got: %d", restored.white.userId)
+	}
+	if !slices.Equal(restored.creators, []string{"ip:192.0.2.9"}) {
+		t.Errorf("expected only the address among the creators to be kept, got: %v", restored.creators)
+	}
+	c := restored.clockResponse()
+	if c == nil || !c.Running || c.WhiteMs > 61000 || c.WhiteMs < 59000 || c.BlackMs < 59000 {
+		t.Errorf("expected the clock to run on from about a minute each, got: %+v", c)
+	}
+	restored.mu.Unlock()
+	if g := getLiveGame(resigned.id).g; g.Result != game.WhiteWon {
+		t.Errorf("expected the resignation to be kept, got: %v", g.Result)
+	}
+	if id := newLiveGame(context.Background(), game.StartGame(), true, nil, nil).id; id <= resigned.id {
+		t.Errorf("expected new games after the restored ones, got id %d", id)
+	}
+}
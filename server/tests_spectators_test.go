@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/game"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSpectators(t *testing.T) {
+	public := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	private := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	private.private = true
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{id}", gameState)
+	mux.HandleFunc("GET /games/{id}/events", gameEvents)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	state := func(lg *liveGame, token string) (int, gameStateResponse, errorResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/games/"+strconv.Itoa(lg.id), nil)
+		if token != "" {
+			req.Header.Set(seatTokenHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var resp gameStateResponse
+		var errResp errorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		json.Unmarshal(rec.Body.Bytes(), &errResp)
+		return rec.Code, resp, errResp
+	}
+
+	if code, _, errResp := state(private, ""); code != http.StatusForbidden || errResp.Code != "private_game" {
+		t.Errorf("GET private game expected 403 private_game, got: %d %+v", code, errResp)
+	}
+	if code, resp, _ := state(private, private.white.token); code != http.StatusOK || !resp.Private {
+		t.Errorf("GET private game with a seat token expected 200, got: %d", code)
+	}
+	if code, _, _ := state(public, "guess"); code != http.StatusForbidden {
+		t.Errorf("GET game with a wrong seat token expected 403, got: %d", code)
+	}
+	resp, err := http.Get(srv.URL + "/games/" + strconv.Itoa(private.id) + "/events")
+	if err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("GET private events expected 403, got: %v %v", resp.StatusCode, err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(srv.URL + "/games/" + strconv.Itoa(public.id) + "/events")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET events expected 200, got: %v", err)
+	}
+	// the player's own stream is not counted
+	seated, err := http.Get(srv.URL + "/games/" + strconv.Itoa(public.id) + "/events?token=" + public.white.token)
+	if err != nil || seated.StatusCode != http.StatusOK {
+		t.Fatalf("GET events with a seat token expected 200, got: %v", err)
+	}
+	defer seated.Body.Close()
+	waitForSpectators := func(expected int) {
+		deadline := time.Now().Add(time.Second)
+		for {
+			_, s, _ := state(public, "")
+			if s.Spectators == expected {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d spectators, got: %d", expected, s.Spectators)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForSpectators(1)
+	resp.Body.Close()
+	waitForSpectators(0)
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/game"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTakeback(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	_, blackToken, _ := lg.claim(lg.black.invite, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /games/{id}/takeback", takeback)
+	post := func(token, action string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/games/"+strconv.Itoa(lg.id)+"/takeback", strings.NewReader(`{"action":"`+action+`"}`))
+		if token != "" {
+			req.Header.Set(seatTokenHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var resp errorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.Code
+	}
+	play := func(notations ...string) {
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		for _, notation := range notations {
+			m, _ := game.ParseMove(notation)
+			if _, err := lg.play(context.Background(), m); err != nil {
+				t.Fatalf("play(%v) error: %v", notation, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		action string
+		eCode  string
+	}{
+		{name: "nothing to take back", token: blackToken, action: "request", eCode: "nothing_to_undo"},
+		{name: "no offer", token: lg.white.token, action: "accept", eCode: "no_takeback_offer"},
+		{name: "spectator", action: "request", eCode: "seat_token_required"},
+		{name: "unknown action", token: lg.white.token, action: "undo", eCode: "invalid_request"},
+	}
+	for _, test := range tests {
+		if _, code := post(test.token, test.action); code != test.eCode {
+			t.Errorf("%v: expected %v, got: %v", test.name, test.eCode, code)
+		}
+	}
+
+	play("e2e4", "a7a6", "e4e5", "d7d5")
+	lg.mu.Lock()
+	passant, _ := lg.g.EnPassantSquare()
+	lg.mu.Unlock()
+	play("e1e2", "a6a5")
+	if status, _ := post(lg.white.token, "request"); status != http.StatusNoContent {
+		t.Fatalf("request takeback expected 204, got: %d", status)
+	}
+	if _, code := post(lg.white.token, "accept"); code != "no_takeback_offer" {
+		t.Errorf("accepting the own takeback expected no_takeback_offer, got: %v", code)
+	}
+	if status, _ := post(blackToken, "accept"); status != http.StatusNoContent {
+		t.Fatalf("accept takeback expected 204, got: %d", status)
+	}
+	lg.mu.Lock()
+	square, ok := lg.g.EnPassantSquare()
+	if len(lg.g.Moves) != 4 || !lg.g.IsWhiteMove || !ok || square != passant || lg.g.CastlingRights().String() != "KQkq" || lg.takebackBy != "" {
+		t.Errorf("takeback expected the position after d7d5, got: %d moves, white: %v, en passant: %v, castling: %v",
+			len(lg.g.Moves), lg.g.IsWhiteMove, square, lg.g.CastlingRights())
+	}
+	lg.mu.Unlock()
+
+	post(blackToken, "request")
+	post(lg.white.token, "decline")
+	lg.mu.Lock()
+	if len(lg.g.Moves) != 4 || lg.takebackBy != "" {
+		t.Errorf("declined takeback expected no change, got: %d moves", len(lg.g.Moves))
+	}
+	lg.mu.Unlock()
+	var types []string
+	backlog, events := lg.events.subscribe(func(gameEvent) bool { return true }, func(gameEvent) bool { return true })
+	lg.events.unsubscribe(events)
+	for _, e := range backlog[len(backlog)-4:] {
+		types = append(types, e.Type)
+	}
+	if strings.Join(types, ",") != "takebackOffer,takeback,takebackOffer,takebackDeclined" {
+		t.Errorf("expected the takeback events, got: %v", types)
+	}
+}
+
+func TestClockTakeBack(t *testing.T) {
+	start := time.Now()
+	c := newClock(time.Minute, 2*time.Second)
+	c.press(start)
+	c.press(start.Add(10 * time.Second))
+	c.takeBack(start.Add(15*time.Second), 1, false)
+	if white, black := c.remaining(start.Add(15 * time.Second)); c.whiteToMove || white != 55*time.Second || black != 52*time.Second {
+		t.Errorf("takeBack() expected black to move again with white at 55s and black at 52s, got: %v %v %v", c.whiteToMove, white, black)
+	}
+	c.takeBack(start.Add(20*time.Second), 1, true)
+	if c.response(start.Add(30*time.Second)).Running || !c.whiteToMove {
+		t.Errorf("takeBack() to the start expected a stopped clock")
+	}
+}
+
+func TestComputerReplyAfterTakeback(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), true, &computerOpponent{isWhite: false}, nil)
+	e4, _ := game.ParseMove("e2e4")
+	d4, _ := game.ParseMove("d2d4")
+	reply, _ := game.ParseMove("e7e5")
+	lg.mu.Lock()
+	lg.play(context.Background(), e4)
+	generation := lg.generation
+	if err := lg.takeBack("white"); err != nil {
+		t.Fatalf("takeBack expected to succeed, got: %v", err)
+	}
+	lg.play(context.Background(), d4)
+	lg.mu.Unlock()
+
+	// the reply to e4 is legal after d4 too, but no longer the computer's
+	if _, _, ok := lg.playReply(context.Background(), reply, generation); ok {
+		t.Error("playReply expected the reply thought of before the takeback to be dropped")
+	}
+	lg.mu.Lock()
+	generation = lg.generation
+	lg.mu.Unlock()
+	if _, _, ok := lg.playReply(context.Background(), reply, generation); !ok {
+		t.Error("playReply expected the reply to the current position to be played")
+	}
+}
+
+func TestWebSocketResumeAfterTakeback(t *testing.T) {
+	lg := newLiveGame(context.Background(), game.StartGame(), true, nil, nil)
+	lg.claim(lg.black.invite, nil)
+	lg.mu.Lock()
+	for _, notation := range []string{"e2e4", "e7e5"} {
+		m, _ := game.ParseMove(notation)
+		lg.play(context.Background(), m)
+	}
+	seen, events := lg.events.subscribe(func(gameEvent) bool { return true }, func(gameEvent) bool { return true })
+	lg.events.unsubscribe(events)
+	// the client drops off after e7e5, black takes it back and plays e7e6 at
+	// the same ply
+	lg.takeBack("black")
+	m, _ := game.ParseMove("e7e6")
+	lg.play(context.Background(), m)
+	lg.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{id}/ws", liveUpdates)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/games/" + strconv.Itoa(lg.id) + "/ws"
+	resumed := dial(t, url+"?lastEventId="+strconv.Itoa(seen[len(seen)-1].Id))
+	if e := receive(t, resumed); e.Type != takebackEvent || e.Ply != 1 {
+		t.Errorf("expected the resumed stream to start with the takeback, got: %+v", e)
+	}
+	if e := receive(t, resumed); e.Type != moveEvent || e.Move != "e7e6" || e.Ply != 2 {
+		t.Errorf("expected e7e6 after the takeback, got: %+v", e)
+	}
+}
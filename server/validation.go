@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxRequestBody = 64 << 10
+	// a move is a few numbers or characters
+	maxMoveBody = 1 << 10
+)
+
+type legacyContextKey struct{}
+
+// fieldError is a detail of an invalid request, Field is the JSON path of the
+// offending value, empty for the body itself.
+type fieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// validateBody checks the JSON body of a request against the schema of the
+// request type before the handler decodes it, so that handlers and the game
+// only see well formed requests. An empty optional body passes as {}, one
+// above maxBody bytes is refused unread. Legacy paths only have the size
+// checked, their clients predate the schema and may send fields it does not
+// know or values the handlers default, such as an empty color.
+func validateBody(request any, optional bool, maxBody int64, handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	components := make(map[string]*schema)
+	root := schemaOf(reflect.TypeOf(request), components)
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+		r.Body.Close()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, BodyTooLarge, map[string]int64{"maxBytes": maxBody})
+			return
+		}
+		if err != nil {
+			writeError(w, InvalidRequest, []fieldError{{Reason: err.Error()}})
+			return
+		}
+		if legacy, _ := r.Context().Value(legacyContextKey{}).(bool); !legacy {
+			if errs := validateJSON(body, optional, root, components); len(errs) > 0 {
+				writeError(w, InvalidRequest, errs)
+				return
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler(w, r)
+	}
+}
+
+// validateJSON returns what is wrong with body, nothing when it satisfies s.
+func validateJSON(body []byte, optional bool, s *schema, components map[string]*schema) []fieldError {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if optional {
+			return nil
+		}
+		return []fieldError{{Reason: "body is required"}}
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return []fieldError{{Reason: err.Error()}}
+	}
+	if dec.More() {
+		return []fieldError{{Reason: "body must hold a single JSON value"}}
+	}
+	var errs []fieldError
+	validateValue(value, s, components, "", &errs)
+	return errs
+}
+
+func validateValue(value any, s *schema, components map[string]*schema, path string, errs *[]fieldError) {
+	if s.Ref != "" {
+		s = components[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	if value == nil {
+		if !s.Nullable {
+			*errs = append(*errs, fieldError{path, "must not be null"})
+		}
+		return
+	}
+	for _, sub := range s.AllOf {
+		validateValue(value, sub, components, path, errs)
+	}
+	if s.Type != "" && !hasType(value, s.Type) {
+		*errs = append(*errs, fieldError{path, "must be of type " + s.Type})
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(value, s.Enum) {
+		*errs = append(*errs, fieldError{path, fmt.Sprintf("must be one of %v", s.Enum)})
+	}
+	switch v := value.(type) {
+	case json.Number:
+		n, _ := v.Float64()
+		if s.Minimum != nil && n < *s.Minimum {
+			*errs = append(*errs, fieldError{path, fmt.Sprintf("must be at least %v", *s.Minimum)})
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			*errs = append(*errs, fieldError{path, fmt.Sprintf("must be at most %v", *s.Maximum)})
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			*errs = append(*errs, fieldError{path, fmt.Sprintf("must be at least %v characters", *s.MinLength)})
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			*errs = append(*errs, fieldError{path, fmt.Sprintf("must be at most %v characters", *s.MaxLength)})
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				validateValue(item, s.Items, components, fmt.Sprintf("%v[%v]", path, i), errs)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, fieldError{joinPath(path, name), "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if property, ok := s.Properties[name]; ok {
+				validateValue(v[name], property, components, joinPath(path, name), errs)
+			} else if additional, ok := s.AdditionalProperties.(*schema); ok {
+				validateValue(v[name], additional, components, joinPath(path, name), errs)
+			} else if s.AdditionalProperties == false {
+				*errs = append(*errs, fieldError{joinPath(path, name), "is not a known field"})
+			}
+		}
+	}
+}
+
+func hasType(value any, t string) bool {
+	switch v := value.(type) {
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	case json.Number:
+		if t == "number" {
+			return true
+		}
+		_, err := v.Int64()
+		return t == "integer" && err == nil
+	}
+	return false
+}
+
+func inEnum(value any, enum []any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
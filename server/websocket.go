@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"lets-go-chess/accounts"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 1024
+)
+
+var upgrader = websocket.Upgrader{CheckOrigin: checkOrigin}
+
+// clientMessage is what a client sends over the socket. Type is one of
+// "move", "offerDraw", "acceptDraw", "declineDraw", "requestTakeback",
+// "acceptTakeback", "declineTakeback", "offerRematch", "acceptRematch",
+// "declineRematch", "chat", "mute" and "unmute". Move is in coordinate
+// notation, e.g. "e2e4", or in standard algebraic notation, e.g. "Nf3", Text
+// the chat message.
+type clientMessage struct {
+	Type string `json:"type"`
+	Move string `json:"move,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+// liveUpdates streams the events of a game over a WebSocket and accepts moves
+// and draw offers on it from the seat holder: a logged in user of the seat or
+// the one passing the seat token as token, browsers cannot set headers on a
+// WebSocket. A reconnecting client passes the id of the last event it has
+// seen as lastEventId and gets the events it missed replayed first. Older
+// clients pass the last ply they have seen as since instead, which misses
+// the events of a takeback.
+func liveUpdates(w http.ResponseWriter, r *http.Request) {
+	lg, seated, ok := watchGame(w, r)
+	if !ok {
+		return
+	}
+	missed := func(gameEvent) bool { return true }
+	query := r.URL.Query()
+	switch {
+	case query.Has("lastEventId"):
+		lastId, err := strconv.Atoi(query.Get("lastEventId"))
+		if err != nil || lastId < 0 {
+			writeError(w, InvalidRequest, "lastEventId must be an event id")
+			return
+		}
+		missed = func(e gameEvent) bool { return e.Id > lastId }
+	case query.Has("since"):
+		since, err := strconv.Atoi(query.Get("since"))
+		if err != nil || since < 0 {
+			writeError(w, InvalidRequest, "since must be a ply number")
+			return
+		}
+		missed = func(e gameEvent) bool { return e.Ply > since || e.Type != moveEvent && e.Ply == since }
+	}
+	l := serverLifecycle()
+	if !l.openStream() {
+		writeError(w, ShuttingDown, nil)
+		return
+	}
+	defer l.closeStream()
+	liveClients.Inc("websocket")
+	defer liveClients.Dec("websocket")
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error upgrading connection", "error", err)
+		return
+	}
+	defer conn.Close()
+	// without a seat the client only watches
+	if seated == nil {
+		defer lg.watch()()
+	}
+
+	lg.mu.Lock()
+	backlog, events := lg.events.subscribe(missed, lg.visibleTo(viewer(seated, r)))
+	lg.mu.Unlock()
+	defer lg.events.unsubscribe(events)
+	replies := make(chan gameEvent, subscriberBuffer)
+	done := make(chan struct{})
+	defer close(done)
+	go writeEvents(conn, backlog, events, replies, done, l.stopping)
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				slog.ErrorContext(r.Context(), "Error reading message", "error", err)
+			}
+			return
+		}
+		var msg clientMessage
+		if err = json.Unmarshal(data, &msg); err == nil {
+			err = handleClientMessage(r.Context(), lg, seated, userFrom(r), msg)
+		} else {
+			err = InvalidRequest
+		}
+		if err != nil {
+			status, resp := toErrorResponse(err, msg)
+			if status >= http.StatusInternalServerError {
+				slog.ErrorContext(r.Context(), "Error handling message", "type", msg.Type, "error", err)
+			}
+			select {
+			case replies <- gameEvent{Type: errorEvent, Error: resp}:
+			default:
+			}
+		}
+	}
+}
+
+// writeEvents owns the writing side of the connection: it sends the backlog,
+// then every new event, replies to this client and heartbeat pings until
+// done, or stopping closes the connection for a shutdown.
+func writeEvents(conn *websocket.Conn, backlog []gameEvent, events, replies <-chan gameEvent, done, stopping <-chan struct{}) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	// closing the connection also stops the reading side
+	defer conn.Close()
+	write := func(e gameEvent) bool {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteJSON(e) == nil
+	}
+	for _, e := range backlog {
+		if !write(e) {
+			return
+		}
+	}
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				// too slow to keep up, the client has to resume
+				message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow")
+				conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+				return
+			}
+			if !write(e) {
+				return
+			}
+		case e := <-replies:
+			if !write(e) {
+				return
+			}
+		case <-ticker.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)) != nil {
+				return
+			}
+		case <-stopping:
+			message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+func handleClientMessage(ctx context.Context, lg *liveGame, seated *bool, u *accounts.User, msg clientMessage) error {
+	if msg.Type == "chat" {
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		return lg.chat(ctx, seated, u, msg.Text)
+	}
+	if seated == nil {
+		return SeatTokenRequired
+	}
+	isWhite := *seated
+	switch msg.Type {
+	case "move":
+		lg.mu.Lock()
+		_, _, err := lg.humanMoveNotation(ctx, isWhite, msg.Move)
+		lg.mu.Unlock()
+		if err == nil {
+			go lg.computerReply(context.WithoutCancel(ctx))
+		}
+		return err
+	case "offerDraw", "acceptDraw", "declineDraw":
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		if msg.Type == "offerDraw" {
+			return lg.offerDraw(colorName(isWhite))
+		}
+		return lg.answerDraw(colorName(isWhite), msg.Type == "acceptDraw")
+	case "requestTakeback":
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		return lg.requestTakeback(colorName(isWhite))
+	case "acceptTakeback", "declineTakeback":
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		return lg.answerTakeback(colorName(isWhite), msg.Type == "acceptTakeback")
+	case "offerRematch":
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		_, err := lg.offerRematch(ctx, colorName(isWhite))
+		return err
+	case "acceptRematch", "declineRematch":
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		_, err := lg.answerRematch(ctx, colorName(isWhite), msg.Type == "acceptRematch")
+		return err
+	case "mute", "unmute":
+		lg.mu.Lock()
+		lg.mute(isWhite, msg.Type == "mute")
+		lg.mu.Unlock()
+		return nil
+	}
+	return InvalidRequest
+}
+
+// checkOrigin accepts the configured frontend and same origin requests.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == viper.GetString("cors.frontend") {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
@@ -1,18 +1,31 @@
 package storage
 
-import "lets-go-chess/game"
+import (
+	"context"
+	"lets-go-chess/game"
+	"log/slog"
+	"sync"
+)
 
+var mu sync.RWMutex
 var storage = make(map[int]*game.Game)
 var nextGameId = 1
 
 func GetGameById(id int) *game.Game {
+	mu.RLock()
+	defer mu.RUnlock()
 	return storage[id]
 }
 
-func SetGame(game *game.Game) int {
+// SetGame stores a new game and returns its id. The context carries the
+// request the game is stored for into the log.
+func SetGame(ctx context.Context, game *game.Game) int {
+	mu.Lock()
+	defer mu.Unlock()
 	defer func() {
 		nextGameId++
 	}()
 	storage[nextGameId] = game
+	slog.DebugContext(ctx, "Game stored", "game_id", nextGameId)
 	return nextGameId
 }
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ChatMessage is a chat line of a game. UserId is zero for anonymous players,
+// Color is the sender's colour in the players' room.
+type ChatMessage struct {
+	Room   string
+	UserId int
+	Color  string
+	From   string
+	Text   string
+	Time   time.Time
+}
+
+var chats = make(map[int][]ChatMessage)
+
+func AppendChat(ctx context.Context, gameId int, message ChatMessage) {
+	mu.Lock()
+	defer mu.Unlock()
+	chats[gameId] = append(chats[gameId], message)
+	slog.DebugContext(ctx, "Chat message stored", "game_id", gameId, "room", message.Room)
+}
+
+// GetChat returns the chat of a game, oldest message first.
+func GetChat(gameId int) []ChatMessage {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]ChatMessage(nil), chats[gameId]...)
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"lets-go-chess/game"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// states is what the server keeps next to a game as JSON, opaque to the
+// storage.
+var states = make(map[int]json.RawMessage)
+
+// savedGame is a game in the snapshot file. The moves are replayed on load,
+// Result also covers the games decided off the board.
+type savedGame struct {
+	Id     int             `json:"id"`
+	Moves  []string        `json:"moves"`
+	Result game.Result     `json:"result"`
+	Chat   []ChatMessage   `json:"chat,omitempty"`
+	State  json.RawMessage `json:"state,omitempty"`
+}
+
+type snapshot struct {
+	NextGameId int         `json:"nextGameId"`
+	Games      []savedGame `json:"games"`
+}
+
+// SetState keeps the server state of a game until the next Flush.
+func SetState(id int, state json.RawMessage) {
+	mu.Lock()
+	defer mu.Unlock()
+	states[id] = state
+}
+
+// DeleteState drops the server state of a game, the game and its chat stay
+// stored.
+func DeleteState(ctx context.Context, id int) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(states, id)
+	slog.DebugContext(ctx, "Game state deleted", "game_id", id)
+}
+
+// GetState returns the server state of a game, nil when there is none.
+func GetState(id int) json.RawMessage {
+	mu.RLock()
+	defer mu.RUnlock()
+	return states[id]
+}
+
+// GameIds returns the ids of the stored games in ascending order.
+func GameIds() []int {
+	mu.RLock()
+	defer mu.RUnlock()
+	ids := make([]int, 0, len(storage))
+	for id := range storage {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Flush writes the games, their chat and state to the file at path. The file
+// is replaced at once, a crash while writing leaves the previous one.
+func Flush(path string) error {
+	mu.RLock()
+	s := snapshot{NextGameId: nextGameId, Games: make([]savedGame, 0, len(storage))}
+	for id, g := range storage {
+		saved := savedGame{Id: id, Result: g.Result, Chat: chats[id], State: states[id]}
+		for _, m := range g.Moves {
+			saved.Moves = append(saved.Moves, m.String())
+		}
+		s.Games = append(s.Games, saved)
+	}
+	mu.RUnlock()
+	slices.SortFunc(s.Games, func(a, b savedGame) int { return a.Id - b.Id })
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load replaces the stored games with those flushed to path. A missing file
+// is an empty storage.
+func Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var s snapshot
+	if err = json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	games := make(map[int]*game.Game, len(s.Games))
+	for _, saved := range s.Games {
+		g := game.StartGame()
+		for _, notation := range saved.Moves {
+			m, err := g.ParseNotation(notation)
+			if err == nil {
+				_, err = g.Play(m)
+			}
+			if err != nil {
+				return fmt.Errorf("game %d move %v: %w", saved.Id, notation, err)
+			}
+		}
+		if g.Result == game.Ongoing && saved.Result != game.Ongoing {
+			g.Finish(saved.Result)
+		}
+		games[saved.Id] = g
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	storage, chats, states = games, make(map[int][]ChatMessage), make(map[int]json.RawMessage)
+	for _, saved := range s.Games {
+		if saved.Chat != nil {
+			chats[saved.Id] = saved.Chat
+		}
+		if saved.State != nil {
+			states[saved.Id] = saved.State
+		}
+	}
+	nextGameId = max(s.NextGameId, 1)
+	return nil
+}
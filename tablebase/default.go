@@ -0,0 +1,62 @@
+package tablebase
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	defaultTablebases *Tablebases
+	loadOnce          sync.Once
+)
+
+// Default returns the tables found in the tablebase.path directory. Missing
+// tables are skipped, so the result may be empty but is never nil.
+func Default() *Tablebases {
+	loadOnce.Do(func() {
+		defaultTablebases = NewTablebases()
+		dir := viper.GetString("tablebase.path")
+		if dir == "" {
+			return
+		}
+		for _, m := range Materials {
+			t, err := Load(dir, m)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					slog.Error("Error loading tablebase", "table", m.Name, "error", err)
+				}
+				continue
+			}
+			defaultTablebases.tables[m.Name] = t
+		}
+	})
+	return defaultTablebases
+}
+
+// GenerateTables builds every supported table into the tablebase.path directory.
+func GenerateTables() {
+	dir := viper.GetString("tablebase.path")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		slog.Error("Error creating tablebase directory", "path", dir, "error", err)
+		os.Exit(1)
+	}
+	tables := make(map[string]*Table)
+	for _, m := range Materials {
+		slog.Info("Generating tablebase", "table", m.Name)
+		t, err := Generate(m, tables)
+		if err != nil {
+			slog.Error("Error generating tablebase", "table", m.Name, "error", err)
+			os.Exit(1)
+		}
+		if err = t.Save(dir); err != nil {
+			slog.Error("Error saving tablebase", "table", m.Name, "error", err)
+			os.Exit(1)
+		}
+		tables[m.Name] = t
+	}
+	slog.Info("Tablebases written", "path", dir)
+}
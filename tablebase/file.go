@@ -0,0 +1,100 @@
+package tablebase
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// A table file starts with the magic, the material name and the number of
+// positions, followed by the gzip compressed value bytes.
+const (
+	magic     = "LGCTB"
+	version   = 1
+	extension = ".lgtb"
+)
+
+var InvalidTable = errors.New("invalid tablebase file")
+
+func fileName(dir string, m *Material) string {
+	return filepath.Join(dir, m.Name+extension)
+}
+
+// Save writes the table into dir, named after its material.
+func (t *Table) Save(dir string) error {
+	f, err := os.Create(fileName(dir, t.Material))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return t.write(f)
+}
+
+func (t *Table) write(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	header := []byte(magic)
+	header = append(header, version, byte(len(t.Material.Name)))
+	header = append(header, t.Material.Name...)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(t.values)))
+	if _, err := buf.Write(header); err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(t.values); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// Load reads the table of the material set from dir.
+func Load(dir string, m *Material) (*Table, error) {
+	f, err := os.Open(fileName(dir, m))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return read(bufio.NewReader(f))
+}
+
+func read(r io.Reader) (*Table, error) {
+	header := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(magic)]) != magic || header[len(magic)] != version {
+		return nil, InvalidTable
+	}
+	name := make([]byte, header[len(magic)+1])
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, err
+	}
+	m := materialByName(string(name))
+	if m == nil {
+		return nil, fmt.Errorf("%w: unknown material %q", InvalidTable, name)
+	}
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if int(size) != m.size() {
+		return nil, fmt.Errorf("%w: %s has %d positions, expected %d", InvalidTable, m.Name, size, m.size())
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	values := make([]byte, size)
+	if _, err = io.ReadFull(zr, values); err != nil {
+		return nil, err
+	}
+	return &Table{Material: m, values: values}, nil
+}
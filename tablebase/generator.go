@@ -0,0 +1,140 @@
+package tablebase
+
+import "fmt"
+
+const (
+	// a value byte holds 0 for a draw, n for a win in n plies and lossFlag|n
+	// for a loss in n plies, always from the point of view of the side to move
+	lossFlag = 0x80
+	maxPlies = 0x7f
+
+	illegal  = 0xff
+	countBit = 0x3f
+	drawExit = 0x40
+	winExit  = 0x80
+)
+
+// Table holds the value of every position of one material set.
+type Table struct {
+	Material *Material
+	values   []byte
+}
+
+// Generate builds the table for the material set by retrograde analysis:
+// starting from checkmates it walks backwards through the positions,
+// resolving them in order of their distance to mate. Tables reached by
+// promotion have to be passed in.
+func Generate(m *Material, dependencies map[string]*Table) (*Table, error) {
+	for _, name := range m.dependencies {
+		if dependencies[name] == nil {
+			return nil, fmt.Errorf("%s requires the %s table", m.Name, name)
+		}
+	}
+	g := &generator{
+		m:      m,
+		values: make([]byte, m.size()),
+		counts: make([]byte, m.size()),
+		wins:   make([][]int32, maxPlies+2),
+		losses: make([][]int32, maxPlies+2),
+	}
+	g.initialize(dependencies)
+	for plies := 0; plies <= maxPlies; plies++ {
+		for _, idx := range g.losses[plies] {
+			g.resolveLoss(int(idx), plies)
+		}
+		g.losses[plies] = nil
+		for _, idx := range g.wins[plies] {
+			g.resolveWin(int(idx), plies)
+		}
+		g.wins[plies] = nil
+	}
+	return &Table{Material: m, values: g.values}, nil
+}
+
+type generator struct {
+	m      *Material
+	values []byte
+	// counts holds the number of moves within the set not yet known to lose,
+	// plus flags for moves leaving the set with a draw or a win
+	counts []byte
+	wins   [][]int32
+	losses [][]int32
+}
+
+func (g *generator) initialize(dependencies map[string]*Table) {
+	for idx := range g.values {
+		p := g.m.position(idx)
+		if !g.m.legal(&p) {
+			g.counts[idx] = illegal
+			continue
+		}
+		count := byte(0)
+		hasMoves := false
+		g.m.moves(&p, func(position) {
+			hasMoves = true
+			count++
+		}, func(e exit) {
+			hasMoves = true
+			switch e.promoted {
+			case queen, rook:
+				table := dependencies[materialName([]piece{e.promoted})]
+				value := table.values[table.Material.index(&e.after)]
+				if value&lossFlag != 0 {
+					count |= winExit
+					g.push(g.wins, idx, int(value&maxPlies)+1)
+				} else {
+					count |= drawExit
+				}
+			default:
+				count |= drawExit
+			}
+		})
+		g.counts[idx] = count
+		if !hasMoves && g.m.inCheck(&p) {
+			g.push(g.losses, idx, 0)
+		}
+	}
+}
+
+func (g *generator) push(queue [][]int32, idx, plies int) {
+	if plies <= maxPlies {
+		queue[plies] = append(queue[plies], int32(idx))
+	}
+}
+
+// resolveLoss marks a lost position, which makes every predecessor a win.
+func (g *generator) resolveLoss(idx, plies int) {
+	if g.values[idx] != 0 {
+		return
+	}
+	g.values[idx] = lossFlag | byte(plies)
+	p := g.m.position(idx)
+	g.m.unmoves(&p, func(prev position) {
+		prevIdx := g.m.index(&prev)
+		if g.counts[prevIdx] != illegal && g.values[prevIdx] == 0 {
+			g.push(g.wins, prevIdx, plies+1)
+		}
+	})
+}
+
+// resolveWin marks a won position. Predecessors whose every move leads to
+// a won position for the opponent are lost.
+func (g *generator) resolveWin(idx, plies int) {
+	if g.values[idx] != 0 {
+		return
+	}
+	g.values[idx] = byte(plies)
+	p := g.m.position(idx)
+	g.m.unmoves(&p, func(prev position) {
+		prevIdx := g.m.index(&prev)
+		count := g.counts[prevIdx]
+		if count == illegal || g.values[prevIdx] != 0 || count&countBit == 0 {
+			return
+		}
+		count--
+		g.counts[prevIdx] = count
+		if count == 0 {
+			g.push(g.losses, prevIdx, plies+1)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package tablebase
+
+import "strings"
+
+type piece int
+
+const (
+	king piece = iota
+	queen
+	rook
+	bishop
+	knight
+	pawn
+)
+
+// Material is a set of pieces of the stronger side playing against a bare
+// king. Tables are always built with white as the stronger side, positions
+// with a stronger black side are mirrored before lookup.
+type Material struct {
+	Name   string
+	extras []piece
+	// dependencies are the tables reached by promotion
+	dependencies []string
+}
+
+var (
+	KQK  = &Material{Name: "KQK", extras: []piece{queen}}
+	KRK  = &Material{Name: "KRK", extras: []piece{rook}}
+	KPK  = &Material{Name: "KPK", extras: []piece{pawn}, dependencies: []string{"KQK", "KRK"}}
+	KBNK = &Material{Name: "KBNK", extras: []piece{bishop, knight}}
+)
+
+// Materials lists the supported sets in generation order, dependencies first.
+var Materials = []*Material{KQK, KRK, KPK, KBNK}
+
+func materialByName(name string) *Material {
+	for _, m := range Materials {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func materialName(extras []piece) string {
+	var name strings.Builder
+	name.WriteString("K")
+	for _, p := range extras {
+		name.WriteString(pieceLetter(p))
+	}
+	name.WriteString("K")
+	return name.String()
+}
+
+func pieceLetter(p piece) string {
+	return [...]string{"K", "Q", "R", "B", "N", "P"}[p]
+}
+
+// size is the number of indexes: squares of the white king, the black king
+// and every extra piece, times the side to move.
+func (m *Material) size() int {
+	size := 2
+	for i := 0; i < 2+len(m.extras); i++ {
+		size *= 64
+	}
+	return size
+}
+
+// position is a compact board used by the generator. Squares are numbered
+// 0-63 from a1 to h8, squares[0] is the white king, squares[1] the black
+// king and the rest are the white extras in material order.
+type position struct {
+	squares     [4]int
+	count       int
+	whiteToMove bool
+}
+
+func (m *Material) index(p *position) int {
+	idx := 0
+	for i := 0; i < p.count; i++ {
+		idx = idx*64 + p.squares[i]
+	}
+	idx *= 2
+	if p.whiteToMove {
+		idx++
+	}
+	return idx
+}
+
+func (m *Material) position(idx int) position {
+	p := position{count: 2 + len(m.extras), whiteToMove: idx%2 == 1}
+	idx /= 2
+	for i := p.count - 1; i >= 0; i-- {
+		p.squares[i] = idx % 64
+		idx /= 64
+	}
+	return p
+}
+
+func (m *Material) kind(i int) piece {
+	if i < 2 {
+		return king
+	}
+	return m.extras[i-2]
+}
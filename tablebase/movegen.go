@@ -0,0 +1,256 @@
+package tablebase
+
+var (
+	kingSteps    = [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+	knightSteps  = [][2]int{{1, 2}, {2, 1}, {-1, 2}, {-2, 1}, {1, -2}, {2, -1}, {-1, -2}, {-2, -1}}
+	rookSteps    = [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	bishopSteps  = [][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+	queenSteps   = append(append([][2]int{}, rookSteps...), bishopSteps...)
+	noSquare     = -1
+	promotionRow = 7
+)
+
+func file(sq int) int { return sq % 8 }
+func row(sq int) int  { return sq / 8 }
+
+func step(sq int, d [2]int) int {
+	x, y := file(sq)+d[0], row(sq)+d[1]
+	if x < 0 || x > 7 || y < 0 || y > 7 {
+		return noSquare
+	}
+	return y*8 + x
+}
+
+func (p *position) occupant(sq int) int {
+	for i := 0; i < p.count; i++ {
+		if p.squares[i] == sq {
+			return i
+		}
+	}
+	return noSquare
+}
+
+// targets lists the squares piece i attacks or moves to, stopping sliders at
+// the first occupied square. Pawns are handled separately.
+func (m *Material) targets(p *position, i int, fn func(sq int)) {
+	var steps [][2]int
+	slider := true
+	switch m.kind(i) {
+	case king:
+		steps, slider = kingSteps, false
+	case knight:
+		steps, slider = knightSteps, false
+	case queen:
+		steps = queenSteps
+	case rook:
+		steps = rookSteps
+	case bishop:
+		steps = bishopSteps
+	case pawn:
+		return
+	}
+	for _, d := range steps {
+		for sq := step(p.squares[i], d); sq != noSquare; sq = step(sq, d) {
+			fn(sq)
+			if !slider || p.occupant(sq) != noSquare {
+				break
+			}
+		}
+	}
+}
+
+// attackedByWhite reports whether sq is attacked by the white pieces. The
+// black king is ignored as a blocker, so that it can not hide behind itself.
+func (m *Material) attackedByWhite(p *position, sq int) bool {
+	saved := p.squares[1]
+	p.squares[1] = noSquare
+	defer func() { p.squares[1] = saved }()
+	for i := 0; i < p.count; i++ {
+		if i == 1 || p.squares[i] == noSquare || p.squares[i] == sq {
+			continue
+		}
+		if m.kind(i) == pawn {
+			if row(sq) == row(p.squares[i])+1 && (file(sq) == file(p.squares[i])+1 || file(sq) == file(p.squares[i])-1) {
+				return true
+			}
+			continue
+		}
+		attacked := false
+		m.targets(p, i, func(target int) {
+			if target == sq {
+				attacked = true
+			}
+		})
+		if attacked {
+			return true
+		}
+	}
+	return false
+}
+
+func kingsTouch(a, b int) bool {
+	dx, dy := file(a)-file(b), row(a)-row(b)
+	return dx >= -1 && dx <= 1 && dy >= -1 && dy <= 1
+}
+
+// legal reports whether the position can occur in a game.
+func (m *Material) legal(p *position) bool {
+	for i := 0; i < p.count; i++ {
+		for j := i + 1; j < p.count; j++ {
+			if p.squares[i] == p.squares[j] {
+				return false
+			}
+		}
+		if m.kind(i) == pawn && (row(p.squares[i]) == 0 || row(p.squares[i]) == promotionRow) {
+			return false
+		}
+	}
+	if kingsTouch(p.squares[0], p.squares[1]) {
+		return false
+	}
+	return !p.whiteToMove || !m.attackedByWhite(p, p.squares[1])
+}
+
+func (m *Material) inCheck(p *position) bool {
+	return !p.whiteToMove && m.attackedByWhite(p, p.squares[1])
+}
+
+// exit describes a move leaving the material set: a capture or a promotion.
+type exit struct {
+	// promoted is the piece a pawn turns into, or king when a piece was captured
+	promoted piece
+	// after is the position after a promotion, with the promoted piece in place
+	after position
+}
+
+// moves calls inSet for every legal move staying within the material set and
+// onExit for every legal move leaving it.
+func (m *Material) moves(p *position, inSet func(next position), onExit func(e exit)) {
+	if p.whiteToMove {
+		m.whiteMoves(p, inSet, onExit)
+	} else {
+		m.blackMoves(p, inSet, onExit)
+	}
+}
+
+func (m *Material) whiteMoves(p *position, inSet func(next position), onExit func(e exit)) {
+	for i := 0; i < p.count; i++ {
+		if i == 1 {
+			continue
+		}
+		if m.kind(i) == pawn {
+			m.pawnMoves(p, i, inSet, onExit)
+			continue
+		}
+		m.targets(p, i, func(sq int) {
+			if p.occupant(sq) != noSquare {
+				return
+			}
+			if i == 0 && kingsTouch(sq, p.squares[1]) {
+				return
+			}
+			next := *p
+			next.squares[i] = sq
+			next.whiteToMove = false
+			inSet(next)
+		})
+	}
+}
+
+func (m *Material) pawnMoves(p *position, i int, inSet func(next position), onExit func(e exit)) {
+	from := p.squares[i]
+	to := from + 8
+	if p.occupant(to) != noSquare {
+		return
+	}
+	next := *p
+	next.squares[i] = to
+	next.whiteToMove = false
+	if row(to) == promotionRow {
+		for _, promoted := range []piece{queen, rook, bishop, knight} {
+			onExit(exit{promoted: promoted, after: next})
+		}
+		return
+	}
+	inSet(next)
+	if row(from) == 1 && p.occupant(to+8) == noSquare {
+		next.squares[i] = to + 8
+		inSet(next)
+	}
+}
+
+func (m *Material) blackMoves(p *position, inSet func(next position), onExit func(e exit)) {
+	for _, d := range kingSteps {
+		sq := step(p.squares[1], d)
+		if sq == noSquare || kingsTouch(sq, p.squares[0]) {
+			continue
+		}
+		next := *p
+		next.squares[1] = sq
+		next.whiteToMove = true
+		captured := next.occupant(sq)
+		if captured > 1 {
+			next.squares[captured] = noSquare
+		}
+		if m.attackedByWhite(&next, sq) {
+			continue
+		}
+		if captured > 1 {
+			onExit(exit{promoted: king})
+			continue
+		}
+		inSet(next)
+	}
+}
+
+// unmoves calls fn for every position from which a non capturing move
+// within the material set leads to p.
+func (m *Material) unmoves(p *position, fn func(prev position)) {
+	if p.whiteToMove {
+		for _, d := range kingSteps {
+			sq := step(p.squares[1], d)
+			if sq == noSquare || p.occupant(sq) != noSquare {
+				continue
+			}
+			prev := *p
+			prev.squares[1] = sq
+			prev.whiteToMove = false
+			fn(prev)
+		}
+		return
+	}
+	for i := 0; i < p.count; i++ {
+		if i == 1 {
+			continue
+		}
+		if m.kind(i) == pawn {
+			m.pawnUnmoves(p, i, fn)
+			continue
+		}
+		m.targets(p, i, func(sq int) {
+			if p.occupant(sq) != noSquare {
+				return
+			}
+			prev := *p
+			prev.squares[i] = sq
+			prev.whiteToMove = true
+			fn(prev)
+		})
+	}
+}
+
+func (m *Material) pawnUnmoves(p *position, i int, fn func(prev position)) {
+	to := p.squares[i]
+	from := to - 8
+	if row(from) < 1 || p.occupant(from) != noSquare {
+		return
+	}
+	prev := *p
+	prev.squares[i] = from
+	prev.whiteToMove = true
+	fn(prev)
+	if row(to) == 3 && p.occupant(from-8) == noSquare {
+		prev.squares[i] = from - 8
+		fn(prev)
+	}
+}
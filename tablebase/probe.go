@@ -0,0 +1,185 @@
+package tablebase
+
+import (
+	"lets-go-chess/game"
+	"sort"
+)
+
+type Outcome int
+
+const (
+	Draw Outcome = iota
+	Win
+	Loss
+)
+
+// Result is the value of a position for the side to move.
+type Result struct {
+	Outcome Outcome
+	Plies   int
+}
+
+// MateIn returns the distance to mate in full moves.
+func (r Result) MateIn() int {
+	return (r.Plies + 1) / 2
+}
+
+// Tablebases is a set of loaded tables.
+type Tablebases struct {
+	tables map[string]*Table
+}
+
+func NewTablebases(tables ...*Table) *Tablebases {
+	tb := &Tablebases{tables: make(map[string]*Table)}
+	for _, t := range tables {
+		tb.tables[t.Material.Name] = t
+	}
+	return tb
+}
+
+// Probe looks the position up. Positions with a bare king against a bare
+// king or a single minor piece are drawn without a table.
+func (tb *Tablebases) Probe(g *game.Game) (Result, bool) {
+	switch g.Result {
+	case game.WhiteWon, game.BlackWon:
+		return Result{Outcome: Loss}, true
+	case game.Draw:
+		return Result{Outcome: Draw}, true
+	}
+	var white, black []figureAt
+	for pos, figure := range g.Field.Cells {
+		if figure == nil {
+			continue
+		}
+		if figure.IsWhite {
+			white = append(white, figureAt{pos: pos, piece: pieceOf(figure.Mover)})
+		} else {
+			black = append(black, figureAt{pos: pos, piece: pieceOf(figure.Mover)})
+		}
+	}
+	strongIsWhite := len(black) == 1
+	strong, weak := white, black
+	if !strongIsWhite {
+		strong, weak = black, white
+	}
+	if len(weak) != 1 {
+		return Result{}, false
+	}
+	sort.Slice(strong, func(i, j int) bool {
+		return strong[i].piece < strong[j].piece
+	})
+	extras := make([]piece, 0, len(strong)-1)
+	for _, f := range strong[1:] {
+		extras = append(extras, f.piece)
+	}
+	if len(extras) == 0 || len(extras) == 1 && (extras[0] == bishop || extras[0] == knight) {
+		return Result{Outcome: Draw}, true
+	}
+	if tb == nil {
+		return Result{}, false
+	}
+	t := tb.tables[materialName(extras)]
+	if t == nil {
+		return Result{}, false
+	}
+	p := position{count: len(strong) + 1, whiteToMove: g.IsWhiteMove == strongIsWhite}
+	p.squares[0] = square(strong[0].pos, strongIsWhite)
+	p.squares[1] = square(weak[0].pos, strongIsWhite)
+	for i, f := range strong[1:] {
+		p.squares[i+2] = square(f.pos, strongIsWhite)
+	}
+	return decode(t.values[t.Material.index(&p)]), true
+}
+
+// BestMove returns the move keeping the best result: the fastest win, a
+// draw, or the slowest loss.
+func (tb *Tablebases) BestMove(g *game.Game) (game.Move, Result, bool) {
+	if _, ok := tb.Probe(g); !ok {
+		return game.Move{}, Result{}, false
+	}
+	var best game.Move
+	var bestResult Result
+	found := false
+	for _, m := range g.LegalMoves() {
+		child := g.Clone()
+		if _, err := child.Play(m); err != nil {
+			continue
+		}
+		childResult, ok := tb.Probe(child)
+		if !ok {
+			return game.Move{}, Result{}, false
+		}
+		result := Result{Outcome: Draw}
+		switch childResult.Outcome {
+		case Loss:
+			result = Result{Outcome: Win, Plies: childResult.Plies + 1}
+		case Win:
+			result = Result{Outcome: Loss, Plies: childResult.Plies + 1}
+		}
+		if !found || result.better(bestResult) {
+			best, bestResult, found = m, result, true
+		}
+	}
+	return best, bestResult, found
+}
+
+func (r Result) better(other Result) bool {
+	if r.Outcome != other.Outcome {
+		return r.rank() > other.rank()
+	}
+	if r.Outcome == Win {
+		return r.Plies < other.Plies
+	}
+	return r.Plies > other.Plies
+}
+
+func (r Result) rank() int {
+	switch r.Outcome {
+	case Win:
+		return 2
+	case Draw:
+		return 1
+	}
+	return 0
+}
+
+func decode(value byte) Result {
+	switch {
+	case value == 0:
+		return Result{Outcome: Draw}
+	case value&lossFlag != 0:
+		return Result{Outcome: Loss, Plies: int(value & maxPlies)}
+	}
+	return Result{Outcome: Win, Plies: int(value)}
+}
+
+type figureAt struct {
+	pos   game.Position
+	piece piece
+}
+
+func pieceOf(mover game.Mover) piece {
+	switch mover.(type) {
+	case game.Queen:
+		return queen
+	case game.Rook:
+		return rook
+	case game.Bishop:
+		return bishop
+	case game.Knight:
+		return knight
+	case game.Pawn:
+		return pawn
+	}
+	return king
+}
+
+// square converts a board position, mirroring the ranks when black is the
+// stronger side.
+func square(pos game.Position, strongIsWhite bool) int {
+	y := pos.Y
+	if !strongIsWhite {
+		y = 9 - y
+	}
+	return (y-1)*8 + pos.X - 1
+}
@@ -0,0 +1,176 @@
+package tablebase
+
+import (
+	"bytes"
+	"lets-go-chess/game"
+	"testing"
+)
+
+var generatedTables map[string]*Table
+
+func generateTables(t *testing.T) map[string]*Table {
+	if generatedTables != nil {
+		return generatedTables
+	}
+	tables := make(map[string]*Table)
+	for _, m := range []*Material{KQK, KRK, KPK} {
+		table, err := Generate(m, tables)
+		if err != nil {
+			t.Fatalf("Generate(%v) error: %v", m.Name, err)
+		}
+		tables[m.Name] = table
+	}
+	generatedTables = tables
+	return tables
+}
+
+func TestGenerateLongestMate(t *testing.T) {
+	tables := generateTables(t)
+	tests := []struct {
+		material *Material
+		eMateIn  int
+	}{
+		{material: KQK, eMateIn: 10},
+		{material: KRK, eMateIn: 16},
+		{material: KPK, eMateIn: 28},
+	}
+	for _, test := range tests {
+		if longest := longestMate(tables[test.material.Name]); longest != test.eMateIn {
+			t.Errorf("%v expected longest mate in %d, got: %d", test.material.Name, test.eMateIn, longest)
+		}
+	}
+	if _, err := Generate(KPK, nil); err == nil {
+		t.Errorf("Generate(KPK) expected error without the KQK and KRK tables")
+	}
+}
+
+// TestGenerateBishopKnightMate checks the bishop and knight moves against the
+// known longest KBNK mate. The table takes about a minute to generate.
+func TestGenerateBishopKnightMate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("KBNK generation is slow")
+	}
+	table, err := Generate(KBNK, generateTables(t))
+	if err != nil {
+		t.Fatalf("Generate(KBNK) error: %v", err)
+	}
+	if longest := longestMate(table); longest != 33 {
+		t.Errorf("KBNK expected longest mate in 33, got: %d", longest)
+	}
+}
+
+func longestMate(table *Table) int {
+	longest := 0
+	for _, value := range table.values {
+		result := decode(value)
+		if result.Outcome == Win && result.MateIn() > longest {
+			longest = result.MateIn()
+		}
+	}
+	return longest
+}
+
+func TestMovesMatchGameRules(t *testing.T) {
+	for _, m := range Materials {
+		for idx := 0; idx < m.size(); idx += m.size()/500 + 1 {
+			p := m.position(idx)
+			if !m.legal(&p) {
+				continue
+			}
+			count := 0
+			m.moves(&p, func(position) { count++ }, func(exit) { count++ })
+			g := gameFromPosition(m, p)
+			if legal := len(g.LegalMoves()); legal != count {
+				t.Errorf("%v index %d expected %d moves by game rules, got: %d", m.Name, idx, legal, count)
+			}
+		}
+	}
+}
+
+func TestBestMoveMates(t *testing.T) {
+	tb := NewTablebases(generateTables(t)["KQK"])
+	g := game.StartGame()
+	g.Field = customField(map[game.Position]*game.Figure{
+		{X: 1, Y: 1}: {IsWhite: false, HasMoved: true, Mover: game.King{}},
+		{X: 3, Y: 2}: {IsWhite: true, HasMoved: true, Mover: game.Queen{}},
+		{X: 3, Y: 3}: {IsWhite: true, HasMoved: true, Mover: game.King{}},
+	})
+	result, ok := tb.Probe(g)
+	if !ok || result.Outcome != Win || result.MateIn() != 1 {
+		t.Fatalf("Probe() expected win in 1, got: %v, %v", result, ok)
+	}
+	m, _, ok := tb.BestMove(g)
+	if !ok {
+		t.Fatalf("BestMove() expected a move")
+	}
+	if situation, err := g.Play(m); err != nil || situation != game.Checkmate {
+		t.Errorf("BestMove() %v expected checkmate, got: %v, %v", m, situation, err)
+	}
+
+	mirrored := game.StartGame()
+	mirrored.IsWhiteMove = false
+	mirrored.Field = customField(map[game.Position]*game.Figure{
+		{X: 1, Y: 8}: {IsWhite: true, HasMoved: true, Mover: game.King{}},
+		{X: 3, Y: 7}: {IsWhite: false, HasMoved: true, Mover: game.Queen{}},
+		{X: 3, Y: 6}: {IsWhite: false, HasMoved: true, Mover: game.King{}},
+	})
+	if result, ok = tb.Probe(mirrored); !ok || result.Outcome != Win || result.MateIn() != 1 {
+		t.Errorf("Probe() with black queen expected win in 1, got: %v, %v", result, ok)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	table := generateTables(t)["KQK"]
+	var buf bytes.Buffer
+	if err := table.write(&buf); err != nil {
+		t.Fatalf("write() error: %v", err)
+	}
+	loaded, err := read(&buf)
+	if err != nil {
+		t.Fatalf("read() error: %v", err)
+	}
+	if loaded.Material != KQK || !bytes.Equal(loaded.values, table.values) {
+		t.Errorf("read() expected the written KQK table")
+	}
+	if _, err = read(bytes.NewReader([]byte("garbage"))); err == nil {
+		t.Errorf("read() expected error for garbage")
+	}
+}
+
+func gameFromPosition(m *Material, p position) *game.Game {
+	figures := make(map[game.Position]*game.Figure)
+	for i := 0; i < p.count; i++ {
+		var mover game.Mover
+		switch m.kind(i) {
+		case king:
+			mover = game.King{}
+		case queen:
+			mover = game.Queen{}
+		case rook:
+			mover = game.Rook{}
+		case bishop:
+			mover = game.Bishop{}
+		case knight:
+			mover = game.Knight{}
+		case pawn:
+			mover = game.Pawn{}
+		}
+		hasMoved := m.kind(i) != pawn || row(p.squares[i]) != 1
+		pos := game.Position{X: file(p.squares[i]) + 1, Y: row(p.squares[i]) + 1}
+		figures[pos] = &game.Figure{IsWhite: i != 1, HasMoved: hasMoved, Mover: mover}
+	}
+	g := game.StartGame()
+	g.Field = customField(figures)
+	g.IsWhiteMove = p.whiteToMove
+	return g
+}
+
+func customField(figures map[game.Position]*game.Figure) game.Board {
+	field := game.Board{Cells: make(map[game.Position]*game.Figure)}
+	for x := 1; x <= 8; x++ {
+		for y := 1; y <= 8; y++ {
+			field.Cells[game.Position{X: x, Y: y}] = figures[game.Position{X: x, Y: y}]
+		}
+	}
+	return field
+}